@@ -0,0 +1,337 @@
+package gopaxos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos/internal/seqrange"
+)
+
+// AdminHandler serves JSON views of the peer for operators:
+//
+//	GET /config                the latest config
+//	GET /status                ID, Min(), Max() and the first undecided instance
+//	GET /instances/{seq}       acceptor state (n_p, n_a, v_a) and decision of seq
+//	GET /decided?from=&to=     decided values of the instances in [from, to],
+//	                           by default the last maxDecidedRange up to Max()
+//	GET /leader                lease holder, Mencius owner and highest ballot
+//	GET /rpc                   RPCStats
+//	GET /snapshot?from=        decided instances from on, see InstallSnapshot
+//
+// and operations on it:
+//
+//	POST /done?seq=            Done(seq)
+//	POST /members              Reconfigure with the JSON adminChange body
+//	POST /leader               AcquireLease
+//	POST /leader/release       ReleaseLease
+//
+// POST /members answers once the change is decided and in effect, with 409
+// if another value or an invalidated change was decided instead, and with
+// 504 if it is not decided within adminDecideTimeout. POST /leader cannot
+// take the lease from a live holder: it only succeeds once no other peer's
+// lease is in force on a prepare quorum, e.g. after POST /leader/release on
+// the holder. It answers 409 as well if an instance below the grant stays
+// undecided for a lease duration: like every read, it does not fill holes.
+//
+// It is not mounted anywhere by default; e.g.
+//
+//	http.Handle("/admin/", http.StripPrefix("/admin", px.AdminHandler()))
+//
+// and then curl localhost:8080/admin/status. It has no access control of
+// its own, so only expose it where operators can reach it.
+func (p *Paxos) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", p.adminConfig)
+	mux.HandleFunc("GET /status", p.adminStatus)
+	mux.HandleFunc("GET /instances/{seq}", p.adminInstance)
+	mux.HandleFunc("GET /decided", p.adminDecided)
+	mux.HandleFunc("GET /leader", p.adminLeader)
+	mux.HandleFunc("GET /rpc", p.adminRPC)
+	mux.HandleFunc("GET /snapshot", p.adminSnapshot)
+	mux.HandleFunc("POST /done", p.adminDone)
+	mux.HandleFunc("POST /members", p.adminMembers)
+	mux.HandleFunc("POST /leader", p.adminAcquireLease)
+	mux.HandleFunc("POST /leader/release", p.adminReleaseLease)
+	return mux
+}
+
+// maxDecidedRange bounds the instances one /decided request returns.
+const maxDecidedRange = 1000
+
+type adminConfig struct {
+	ID            int      `json:"id"`
+	ClusterID     string   `json:"cluster_id"`
+	Epoch         int      `json:"epoch"`
+	Start         int      `json:"start"`
+	Peers         []string `json:"peers"`
+	Voters        []int    `json:"voters"`
+	Learners      []int    `json:"learners"`
+	PrepareQuorum int      `json:"prepare_quorum"`
+	AcceptQuorum  int      `json:"accept_quorum"`
+	Quorums       string   `json:"quorums,omitempty"`
+}
+
+type adminStatus struct {
+	ID       int `json:"id"`
+	Min      int `json:"min"`
+	Max      int `json:"max"`
+	Next     int `json:"next"` // lowest undecided instance
+	InFlight int `json:"in_flight"`
+}
+
+// adminAcceptor is the state of an acceptor in one instance, in the terms
+// of the Paxos papers: n_p is the highest round promised, n_a and v_a the
+// round and value of the last vote, n_a is -1 if none.
+type adminAcceptor struct {
+	NP int `json:"n_p"`
+	NA int `json:"n_a"`
+	VA any `json:"v_a"`
+}
+
+type adminInstance struct {
+	Seq         int            `json:"seq"`
+	Decided     bool           `json:"decided"`
+	Value       any            `json:"value,omitempty"`
+	Acceptor    *adminAcceptor `json:"acceptor,omitempty"`    // round-based instances
+	Generalized *adminAcceptor `json:"generalized,omitempty"` // AppendCommand instances
+}
+
+type adminDecided struct {
+	Seq   int `json:"seq"`
+	Value any `json:"value"`
+}
+
+// adminChange is the body of POST /members. Seq is the instance to propose
+// the change in, Max()+1 if absent.
+type adminChange struct {
+	Op   string `json:"op"` // a ConfigOp, e.g. "AddPeer"
+	ID   int    `json:"id"`
+	Addr string `json:"addr"`
+	Seq  *int   `json:"seq,omitempty"`
+}
+
+// adminDecideTimeout bounds how long POST /members waits for the change.
+const adminDecideTimeout = 5 * time.Second
+
+type adminLeader struct {
+	HoldsLease   bool       `json:"holds_lease"`
+	LeaseHolder  int        `json:"lease_holder"` // granted by this voter, -1 if none
+	LeaseUntil   *time.Time `json:"lease_until,omitempty"`
+	MenciusOwner *int       `json:"mencius_owner,omitempty"` // of the next instance
+	Ballot       int        `json:"ballot"`                  // highest round promised, -1 if none
+}
+
+func (p *Paxos) adminConfig(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	c := p.configs.latest()
+	p.mu.Unlock()
+	view := adminConfig{
+		ID:            p.id,
+		ClusterID:     p.clusterID,
+		Epoch:         c.Epoch,
+		Start:         c.Start,
+		Peers:         c.Peers,
+		Voters:        c.Voters(),
+		Learners:      c.Learners,
+		PrepareQuorum: c.PrepareQuorum,
+		AcceptQuorum:  c.AcceptQuorum,
+	}
+	if c.Quorums != nil {
+		view.Quorums = fmt.Sprintf("%T", c.Quorums)
+	}
+	writeJSON(w, view)
+}
+
+func (p *Paxos) adminStatus(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	view := adminStatus{ID: p.id, Min: p.minSeq, Max: p.maxSeq, Next: p.logger.next}
+	p.mu.Unlock()
+	view.InFlight = p.InFlight()
+	writeJSON(w, view)
+}
+
+func (p *Paxos) adminInstance(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.Atoi(r.PathValue("seq"))
+	if err != nil {
+		http.Error(w, "invalid seq: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	view := adminInstance{Seq: seq}
+	if v, ok := p.logger.Get(seq); ok {
+		view.Decided, view.Value = true, jsonValue(v)
+	}
+	if inst, ok := p.fast[seq]; ok {
+		view.Acceptor = &adminAcceptor{NP: inst.rnd, NA: inst.vrnd, VA: jsonValue(inst.vval)}
+	}
+	if inst, ok := p.general[seq]; ok {
+		view.Generalized = &adminAcceptor{NP: inst.rnd, NA: inst.vrnd, VA: jsonValue(inst.vval)}
+	}
+	p.mu.Unlock()
+	writeJSON(w, view)
+}
+
+func (p *Paxos) adminDecided(w http.ResponseWriter, r *http.Request) {
+	from, to, err := seqrange.FromQuery(r, p.Min(), p.Max(), maxDecidedRange)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	view := []adminDecided{}
+	p.mu.Lock()
+	for seq, v := range p.logger.data {
+		if seq >= from && seq <= to {
+			view = append(view, adminDecided{Seq: seq, Value: jsonValue(v)})
+		}
+	}
+	p.mu.Unlock()
+	sort.Slice(view, func(i, j int) bool { return view[i].Seq < view[j].Seq })
+	writeJSON(w, view)
+}
+
+func (p *Paxos) adminLeader(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	now := p.clock.Now()
+	view := adminLeader{
+		HoldsLease:  now.Before(p.lease.expiry),
+		LeaseHolder: -1,
+		Ballot:      -1,
+	}
+	if now.Before(p.lease.holderTill) {
+		till := p.lease.holderTill
+		view.LeaseHolder, view.LeaseUntil = p.lease.holder, &till
+	}
+	if p.mencius != nil {
+		if c, ok := p.configFor(p.logger.next); ok {
+			owner := c.Owner(p.logger.next)
+			view.MenciusOwner = &owner
+		}
+	}
+	for _, inst := range p.fast {
+		view.Ballot = max(view.Ballot, inst.rnd)
+	}
+	for _, inst := range p.general {
+		view.Ballot = max(view.Ballot, inst.rnd)
+	}
+	p.mu.Unlock()
+	writeJSON(w, view)
+}
+
+func (p *Paxos) adminRPC(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, p.RPCStats())
+}
+
+func (p *Paxos) adminSnapshot(w http.ResponseWriter, r *http.Request) {
+	from := p.Min()
+	if s := r.URL.Query().Get("from"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		from = n
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	p.WriteSnapshot(w, from)
+}
+
+func (p *Paxos) adminDone(w http.ResponseWriter, r *http.Request) {
+	seq, err := strconv.Atoi(r.URL.Query().Get("seq"))
+	if err != nil {
+		http.Error(w, "invalid seq: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.Done(seq)
+	p.adminStatus(w, r)
+}
+
+func (p *Paxos) adminMembers(w http.ResponseWriter, r *http.Request) {
+	var change adminChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, "invalid change: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	op, err := ParseConfigOp(change.Op)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if change.Seq == nil {
+		seq := p.Max() + 1
+		change.Seq = &seq
+	}
+	seq, ch := *change.Seq, ConfigChange{Op: op, ID: change.ID, Addr: change.Addr}
+	if seq < 0 {
+		http.Error(w, "invalid seq: "+strconv.Itoa(seq), http.StatusBadRequest)
+		return
+	}
+	if err := p.Reconfigure(seq, ch); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), adminDecideTimeout)
+	defer cancel()
+	p.mu.Lock()
+	start := seq + p.alpha
+	p.mu.Unlock()
+	for {
+		decided, v := p.Status(seq)
+		if decided && v != Value(ch) {
+			http.Error(w, fmt.Sprintf("instance %d decided %v instead", seq, v), http.StatusConflict)
+			return
+		}
+		// the config from start on is known once every change up to seq is.
+		if config, ok := p.Config(start); decided && ok {
+			if config.Start != start {
+				http.Error(w, fmt.Sprintf("change decided in instance %d is invalid against the changes before it", seq), http.StatusConflict)
+				return
+			}
+			writeJSON(w, change)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			http.Error(w, fmt.Sprintf("change proposed in instance %d, not decided yet: %v", seq, ctx.Err()), http.StatusGatewayTimeout)
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (p *Paxos) adminAcquireLease(w http.ResponseWriter, r *http.Request) {
+	if !p.AcquireLease() {
+		http.Error(w, "lease not granted", http.StatusConflict)
+		return
+	}
+	p.adminLeader(w, r)
+}
+
+func (p *Paxos) adminReleaseLease(w http.ResponseWriter, r *http.Request) {
+	if !p.ReleaseLease() {
+		http.Error(w, "lease not released on a quorum", http.StatusConflict)
+		return
+	}
+	p.adminLeader(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// jsonValue returns v if it can be encoded as JSON, and its %v form
+// otherwise.
+func jsonValue(v Value) any {
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return v
+}
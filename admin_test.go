@@ -0,0 +1,220 @@
+package gopaxos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// getJSON serves GET path from h and decodes the JSON answer into v.
+func getJSON(t *testing.T, h http.Handler, path string, v any) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	if rec.Code == http.StatusOK {
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("%s: content type %q", path, ct)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: %v: %s", path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+func TestAdminHandler(t *testing.T) {
+	fmt.Println("Test: Admin endpoints report the peer's state ...")
+
+	peers := []string{"a:1/x", "b:1/x", "c:1/x"}
+	p := newPaxos(peers, 2, WithLearners(1))
+	h := NewHandler(p)
+	for seq := 0; seq < 3; seq++ {
+		h.OnReceiveDecision(&Request{FromID: 0, Seq: seq, Value: fmt.Sprint("v", seq)}, &Response{})
+	}
+	h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 5, Round: 3}, &Response{})
+	h.OnReceiveFastAccept(&Request{FromID: 0, Seq: 5, Round: 3, Value: 42}, &Response{})
+	h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 5, Round: 6}, &Response{})
+	admin := p.AdminHandler()
+
+	var config adminConfig
+	getJSON(t, admin, "/config", &config)
+	if config.ID != 2 || len(config.Peers) != 3 || len(config.Voters) != 2 || config.ClusterID != p.ClusterID() {
+		t.Fatalf("config %+v", config)
+	}
+
+	var status adminStatus
+	getJSON(t, admin, "/status", &status)
+	if status.ID != 2 || status.Max != 2 || status.Next != 3 {
+		t.Fatalf("status %+v", status)
+	}
+
+	var inst adminInstance
+	getJSON(t, admin, "/instances/5", &inst)
+	if inst.Decided || inst.Acceptor == nil || inst.Acceptor.NP != 6 || inst.Acceptor.NA != 3 || inst.Acceptor.VA != 42.0 {
+		t.Fatalf("instance %+v, acceptor %+v", inst, inst.Acceptor)
+	}
+	getJSON(t, admin, "/instances/1", &inst)
+	if !inst.Decided || inst.Value != "v1" {
+		t.Fatalf("instance %+v", inst)
+	}
+	if code := getJSON(t, admin, "/instances/x", &inst); code != http.StatusBadRequest {
+		t.Fatalf("invalid seq answered %d", code)
+	}
+
+	var decided []adminDecided
+	getJSON(t, admin, "/decided?from=1&to=5", &decided)
+	if len(decided) != 2 || decided[0].Seq != 1 || decided[1].Value != "v2" {
+		t.Fatalf("decided %+v", decided)
+	}
+	if code := getJSON(t, admin, "/decided?from=0&to=100000", &decided); code != http.StatusBadRequest {
+		t.Fatalf("huge range answered %d", code)
+	}
+	if code := getJSON(t, admin, "/decided?from=2&to=1", &decided); code != http.StatusBadRequest {
+		t.Fatalf("reversed range answered %d", code)
+	}
+	for seq := 3; seq < 2*maxDecidedRange; seq++ {
+		h.OnReceiveDecision(&Request{FromID: 0, Seq: seq, Value: seq}, &Response{})
+	}
+	// without bounds, the last instances up to Max().
+	if code := getJSON(t, admin, "/decided", &decided); code != http.StatusOK || len(decided) != maxDecidedRange || decided[0].Seq != maxDecidedRange {
+		t.Fatalf("decided without bounds answered %d with %d instances", code, len(decided))
+	}
+	if code := getJSON(t, admin, "/decided?from=10", &decided); code != http.StatusOK || len(decided) != maxDecidedRange || decided[0].Seq != 10 {
+		t.Fatalf("decided from 10 answered %d with %d instances", code, len(decided))
+	}
+
+	var leader adminLeader
+	getJSON(t, admin, "/leader", &leader)
+	if leader.HoldsLease || leader.LeaseHolder != -1 || leader.Ballot != 6 || leader.MenciusOwner != nil {
+		t.Fatalf("leader %+v", leader)
+	}
+
+	var stats RPCStats
+	if code := getJSON(t, admin, "/rpc", &stats); code != http.StatusOK {
+		t.Fatalf("rpc answered %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest("POST", "/status", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST answered %d", rec.Code)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	fmt.Println("Test: A snapshot installs on another peer ...")
+
+	peers := []string{"a:1/x", "b:1/x", "c:1/x"}
+	p := newPaxos(peers, 0)
+	for seq := 0; seq < 5; seq++ {
+		p.decide(seq, seq*10)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteSnapshot(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	q := newPaxos(peers, 1)
+	if err := q.InstallSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for seq := 0; seq < 5; seq++ {
+		decided, v := q.Status(seq)
+		if decided != (seq >= 2) || (decided && v != seq*10) {
+			t.Fatalf("seq %d: decided %v, value %v", seq, decided, v)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosAdminMembers(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("admin-members", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithAlpha(1))
+	}
+
+	fmt.Println("Test: Admin membership changes answer once decided ...")
+
+	admin := pxa[0].AdminHandler()
+	post := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest("POST", "/members", bytes.NewBufferString(body)))
+		return rec.Code, rec.Body.String()
+	}
+
+	// instance 0 can be targeted explicitly.
+	if code, body := post(`{"op": "RemovePeer", "id": 2, "seq": 0}`); code != http.StatusOK {
+		t.Fatalf("remove peer 2 in instance 0 answered %d: %s", code, body)
+	}
+	if decided, v := pxa[0].Status(0); !decided || v != (ConfigChange{Op: RemovePeer, ID: 2}) {
+		t.Fatalf("Status(0) = %v, %v, want: the change", decided, v)
+	}
+	if code, body := post(`{"op": "RemovePeer", "id": 1, "seq": 0}`); code != http.StatusConflict {
+		t.Fatalf("another change in decided instance 0 answered %d: %s, want: %d", code, body, http.StatusConflict)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosAdminLeaderLeavesHoles(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("admin-leader-holes", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithClock(clock), WithLease(time.Second, 0.1))
+	}
+
+	fmt.Println("Test: Admin lease acquisition leaves holes to proposers ...")
+
+	admin := pxa[2].AdminHandler()
+	post := func() int {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest("POST", "/leader", nil))
+		return rec.Code
+	}
+
+	// seq 1 was never used.
+	pxa[0].Start(0, "a")
+	pxa[0].Start(2, "b")
+	if err := waitN(pxa, 2, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if code := post(); code != http.StatusConflict {
+		t.Fatalf("POST /leader over a hole answered %d, want: %d", code, http.StatusConflict)
+	}
+	if n, err := ndecided(pxa, 1); n != 0 || err != nil {
+		t.Fatalf("POST /leader decided hole 1: %v", err)
+	}
+
+	pxa[2].Start(1, 300)
+	if err := waitN(pxa, 1, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("POST /leader answered %d, want: %d", code, http.StatusOK)
+	}
+	if decided, v := pxa[0].Status(1); !decided || v != 300 {
+		t.Fatalf("Status(1) = %v, %v, want: true, 300", decided, v)
+	}
+
+	fmt.Println("  ... Passed")
+}
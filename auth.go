@@ -0,0 +1,188 @@
+package gopaxos
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// With WithKeys every Request and Response carries an HMAC-SHA256 over its
+// fields and the RPC method, computed with a key shared by the cluster. A
+// process without the key cannot forge a message, so it cannot vote or
+// propose in another peer's name. A response MAC also covers the MAC of
+// its request, which ties the answer to the question.
+//
+// Keys are rotated without downtime: first add the new key to every peer as
+// a secondary key, then make it the signing key everywhere, then drop the
+// old one.
+//
+// The MAC is computed over the gob encoding of the message, so Values must
+// encode the same way every time, e.g. they must not contain maps.
+
+// ErrBadMAC is returned for a message whose MAC does not verify.
+var ErrBadMAC = errors.New("gopaxos: message authentication failed")
+
+// Key is a shared cluster key. ID tells receivers which key signed a
+// message.
+type Key struct {
+	ID     uint32
+	Secret []byte
+}
+
+type keyring struct {
+	mu   sync.Mutex
+	keys []Key // keys[0] signs, all verify; empty disables authentication
+}
+
+// WithKeys enables message authentication. The first key signs outgoing
+// messages, and any of them verifies incoming ones. Every peer of the
+// cluster must enable it.
+func WithKeys(keys ...Key) Option {
+	if err := checkKeys(keys); err != nil {
+		panic(err.Error())
+	}
+	return func(p *Paxos) {
+		p.keys.keys = keys
+	}
+}
+
+// RotateKeys replaces the keys of a peer that runs with WithKeys.
+func (p *Paxos) RotateKeys(keys ...Key) error {
+	if err := checkKeys(keys); err != nil {
+		return err
+	}
+	p.keys.mu.Lock()
+	defer p.keys.mu.Unlock()
+	p.keys.keys = keys
+	return nil
+}
+
+func checkKeys(keys []Key) error {
+	if len(keys) == 0 {
+		return errors.New("invalid keys, want: at least one")
+	}
+	ids := make(map[uint32]bool)
+	for _, k := range keys {
+		if len(k.Secret) < 16 || ids[k.ID] {
+			return fmt.Errorf("invalid key %d, want: unique id and at least 16 bytes of secret", k.ID)
+		}
+		ids[k.ID] = true
+	}
+	return nil
+}
+
+// signing returns the signing key, or false if authentication is off.
+func (kr *keyring) signing() (Key, bool) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if len(kr.keys) == 0 {
+		return Key{}, false
+	}
+	return kr.keys[0], true
+}
+
+// verify checks mac against data with key id. It passes everything when
+// authentication is off.
+func (kr *keyring) verify(id uint32, data, mac []byte) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if len(kr.keys) == 0 {
+		return nil
+	}
+	for _, k := range kr.keys {
+		if k.ID == id {
+			if hmac.Equal(mac, computeMAC(k.Secret, data)) {
+				return nil
+			}
+			break
+		}
+	}
+	return ErrBadMAC
+}
+
+func computeMAC(secret, data []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// requestData returns the bytes a request MAC covers.
+func requestData(method string, req *Request) []byte {
+	unsigned := *req
+	unsigned.MAC = nil
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(struct {
+		Method string
+		Req    Request
+	}{method, unsigned})
+	return buf.Bytes()
+}
+
+// responseData returns the bytes a response MAC covers.
+func responseData(method string, req *Request, resp *Response) []byte {
+	unsigned := *resp
+	unsigned.MAC = nil
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(struct {
+		Method string
+		ReqMAC []byte
+		Resp   Response
+	}{method, req.MAC, unsigned})
+	return buf.Bytes()
+}
+
+// signRequest returns req signed for method, or req itself if
+// authentication is off.
+func (p *Paxos) signRequest(method string, req *Request) *Request {
+	k, ok := p.keys.signing()
+	if !ok {
+		return req
+	}
+	signed := *req
+	signed.KeyID, signed.MAC = k.ID, nil
+	signed.MAC = computeMAC(k.Secret, requestData(method, &signed))
+	return &signed
+}
+
+// SignRequest stamps req with this peer's id, cluster ID, config epoch and
+// Forget and signs it for method, like the requests of Paxos itself. Transports
+// call it for requests they send on their own, e.g. for a snapshot.
+func (h *Handler) SignRequest(method string, req *Request) *Request {
+	p := h.pxs
+	stamped := *req
+	p.mu.Lock()
+	stamped.FromID, stamped.ClusterID, stamped.Epoch = p.id, p.clusterID, p.currentConfig().Epoch
+	stamped.Forget = p.forgetBelow[p.id]
+	p.mu.Unlock()
+	return p.signRequest(method, &stamped)
+}
+
+// verifyResponse checks the MAC of the response to req.
+func (p *Paxos) verifyResponse(method string, req *Request, resp *Response) error {
+	return p.keys.verify(resp.KeyID, responseData(method, req, resp), resp.MAC)
+}
+
+// VerifyRequest checks the MAC of a request for method, e.g.
+// "Handler.OnReceiveDecision". Transports call it before the Handler
+// method.
+func (h *Handler) VerifyRequest(method string, req *Request) error {
+	return h.pxs.keys.verify(req.KeyID, requestData(method, req), req.MAC)
+}
+
+// SignResponse stamps the response of a Handler method to req with this
+// peer's Forget and signs it. Transports call it after the Handler method.
+func (h *Handler) SignResponse(method string, req *Request, resp *Response) {
+	h.pxs.mu.Lock()
+	resp.Forget = h.pxs.forgetBelow[h.pxs.id]
+	h.pxs.mu.Unlock()
+	k, ok := h.pxs.keys.signing()
+	if !ok {
+		return
+	}
+	resp.KeyID, resp.MAC = k.ID, nil
+	resp.MAC = computeMAC(k.Secret, responseData(method, req, resp))
+}
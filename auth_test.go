@@ -0,0 +1,99 @@
+package gopaxos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMessageAuthentication(t *testing.T) {
+	fmt.Println("Test: Requests are signed and verified ...")
+
+	oldKey := Key{ID: 1, Secret: []byte("0123456789abcdef")}
+	newKey := Key{ID: 2, Secret: []byte("fedcba9876543210")}
+	sender := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 0, WithKeys(oldKey))
+	receiver := NewHandler(newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 1, WithKeys(oldKey)))
+
+	method := "Handler.OnReceiveDecision"
+	req := sender.signRequest(method, &Request{FromID: 0, Seq: 3, Value: CStruct{"x"}})
+	if err := receiver.VerifyRequest(method, req); err != nil {
+		t.Fatalf("VerifyRequest() = %v, want: nil", err)
+	}
+	if err := receiver.VerifyRequest("Handler.OnReceiveFastAccept", req); err != ErrBadMAC {
+		t.Fatalf("VerifyRequest() for another method = %v, want: %v", err, ErrBadMAC)
+	}
+	forged := *req
+	forged.FromID = 2
+	if err := receiver.VerifyRequest(method, &forged); err != ErrBadMAC {
+		t.Fatalf("VerifyRequest() with a forged FromID = %v, want: %v", err, ErrBadMAC)
+	}
+	if err := receiver.VerifyRequest(method, &Request{FromID: 0, Seq: 3}); err != ErrBadMAC {
+		t.Fatalf("VerifyRequest() of an unsigned request = %v, want: %v", err, ErrBadMAC)
+	}
+
+	var resp Response
+	resp.OK = true
+	receiver.SignResponse(method, req, &resp)
+	if err := sender.verifyResponse(method, req, &resp); err != nil {
+		t.Fatalf("verifyResponse() = %v, want: nil", err)
+	}
+	resp.OK = false
+	if err := sender.verifyResponse(method, req, &resp); err != ErrBadMAC {
+		t.Fatalf("verifyResponse() of a tampered response = %v, want: %v", err, ErrBadMAC)
+	}
+
+	// rotation: the receiver learns the new key first, then the sender
+	// switches to it.
+	receiver.pxs.RotateKeys(oldKey, newKey)
+	if err := receiver.VerifyRequest(method, req); err != nil {
+		t.Fatalf("VerifyRequest() with the old key during rotation = %v, want: nil", err)
+	}
+	sender.RotateKeys(newKey)
+	req = sender.signRequest(method, &Request{FromID: 0, Seq: 4})
+	if err := receiver.VerifyRequest(method, req); err != nil {
+		t.Fatalf("VerifyRequest() with the new key = %v, want: nil", err)
+	}
+	receiver.pxs.RotateKeys(newKey)
+	if err := receiver.VerifyRequest(method, sender.signRequest(method, &Request{})); err != nil {
+		t.Fatalf("VerifyRequest() after rotation = %v, want: nil", err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosMessageAuthentication(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	key := Key{ID: 7, Secret: []byte("cluster secret key")}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("message-authentication", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithKeys(key))
+	}
+
+	fmt.Println("Test: Peers reject forged messages ...")
+
+	pxa[0].StartFast(0, "hello")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	// a process with the wrong key, or none, cannot vote as peer 1.
+	for _, opts := range [][]Option{{WithKeys(Key{ID: 7, Secret: []byte("a guessed secret")})}, nil} {
+		outsider := newPaxos(pxh, 1, opts...)
+		var resp Response
+		err := outsider.callPeer(context.Background(), pxh[0], "Handler.OnReceiveFastAccept",
+			&Request{FromID: 1, Seq: 1, Round: fastRound, Value: "forged"}, &resp)
+		outsider.Kill()
+		if err == nil || !strings.Contains(err.Error(), ErrBadMAC.Error()) {
+			t.Fatalf("forged request = %v, want: %v", err, ErrBadMAC)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,158 @@
+package gopaxos
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"time"
+)
+
+// Submit batches client values: it collects them until WithBatching's size
+// or delay is reached and decides the whole batch in one instance, run in a
+// fast round like StartFast. In Mencius mode, where only the owner of an
+// instance may propose in round 0, the batch goes through Start in an
+// instance this peer owns instead. Status of that instance returns the
+// Batch.
+// Values must be gob-registered like any other Value.
+
+// Batch is the value decided in an instance filled by Submit. From and Num
+// identify the batch, so the proposer can tell its own batch from one
+// another peer got decided in the same instance.
+type Batch struct {
+	From   int
+	Num    int
+	Values []Value
+}
+
+// ErrKilled reports a value the peer gave up on because it was killed.
+var ErrKilled = errors.New("gopaxos: peer killed")
+
+// BatchResult tells a Submit caller where its value was decided: as
+// Status(Seq).(Batch).Values[Index]. Err is set instead if the value was
+// not decided.
+type BatchResult struct {
+	Seq   int
+	Index int
+	Err   error
+}
+
+type batchState struct {
+	size    int
+	delay   time.Duration
+	num     int                // batches proposed by this peer
+	next    int                // lowest seq not yet tried by Submit
+	pending []Value            // values of the open batch
+	results []chan BatchResult // one per pending value
+	timer   *time.Timer        // flushes the open batch after delay
+}
+
+// WithBatching makes Submit decide up to size values per instance, waiting
+// at most delay for a batch to fill. Without it every Submit gets its own
+// instance.
+func WithBatching(size int, delay time.Duration) Option {
+	if size < 1 || delay < 0 {
+		panic("invalid batching, want: size >= 1 and delay >= 0")
+	}
+	return func(p *Paxos) {
+		p.batch.size = size
+		p.batch.delay = delay
+	}
+}
+
+// Submit adds v to the open batch. The returned channel receives the
+// instance and position v was decided at, or ErrKilled if the peer is
+// killed first.
+func (p *Paxos) Submit(v Value) <-chan BatchResult {
+	result := make(chan BatchResult, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b := &p.batch
+	b.pending = append(b.pending, v)
+	b.results = append(b.results, result)
+	if len(b.pending) >= b.size {
+		p.flushBatch()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.delay, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.flushBatch()
+		})
+	}
+	return result
+}
+
+// flushBatch proposes the open batch. p.mu must be held.
+func (p *Paxos) flushBatch() {
+	b := &p.batch
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	batch := Batch{From: p.id, Num: b.num, Values: b.pending}
+	results := b.results
+	b.num++
+	b.pending, b.results = nil, nil
+	go p.proposeBatch(p.ctx, batch, results)
+}
+
+// proposeBatch decides batch in the lowest free instance it can win and
+// tells every Submit caller where its value ended up. When ctx is done it
+// gives up and sends ErrKilled to every caller instead.
+func (p *Paxos) proposeBatch(ctx context.Context, batch Batch, results []chan BatchResult) {
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		seq := p.batch.next
+		if seq <= p.logger.max {
+			seq = p.logger.max + 1
+		}
+		if p.mencius != nil {
+			if config, ok := p.configFor(seq); ok {
+				for i := 0; i < len(config.Voters()) && config.Owner(seq) != p.id; i++ {
+					seq++
+				}
+			}
+		}
+		p.batch.next = seq + 1
+		mencius := p.mencius != nil
+		p.mu.Unlock()
+
+		if mencius {
+			p.Start(seq, batch)
+		} else {
+			p.StartFast(seq, batch)
+		}
+		var v Value
+		for decided := false; !decided && ctx.Err() == nil; {
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Millisecond):
+				decided, v = p.Status(seq)
+			}
+		}
+		if won, ok := v.(Batch); ok && won.From == batch.From && won.Num == batch.Num {
+			for i, result := range results {
+				result <- BatchResult{Seq: seq, Index: i}
+			}
+			return
+		}
+	}
+	for _, result := range results {
+		result <- BatchResult{Err: ErrKilled}
+	}
+}
+
+// voteKey returns a comparable stand-in for v, so values that are not
+// comparable with ==, like Batch, can be counted as votes.
+func voteKey(v Value) Value {
+	if b, ok := v.(Batch); ok {
+		return [2]int{b.From, b.Num}
+	}
+	return v
+}
+
+func init() {
+	gob.Register(Batch{})
+}
@@ -0,0 +1,130 @@
+package gopaxos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBatchPickValue(t *testing.T) {
+	fmt.Println("Test: Batches are counted as fast round votes ...")
+
+	a := Batch{From: 0, Num: 0, Values: []Value{"x", "y"}}
+	b := Batch{From: 1, Num: 0, Values: []Value{"x"}}
+	promises := []Response{
+		{Round: fastRound, Value: a},
+		{Round: fastRound, Value: Batch{From: 0, Num: 0, Values: []Value{"x", "y"}}},
+		{Round: fastRound, Value: b},
+	}
+	w, ok := pickFastValue(promises, 5, 4, "mine").(Batch)
+	if !ok || w.From != a.From || w.Num != a.Num {
+		t.Fatalf("pickFastValue() = %v, want: %v", w, a)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosBatching(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("batching", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithBatching(4, 50*time.Millisecond))
+	}
+
+	fmt.Println("Test: Batching many values per instance ...")
+
+	nvalues := 10
+	results := make(map[Value]<-chan BatchResult)
+	for i := 0; i < nvalues; i++ {
+		v := fmt.Sprintf("v%d", i)
+		results[v] = pxa[i%2].Submit(v)
+	}
+
+	seqs := make(map[int]bool)
+	for v, ch := range results {
+		var r BatchResult
+		select {
+		case r = <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Submit(%v) not decided", v)
+		}
+		// waitN compares values with ==, which a Batch does not support.
+		var got Value
+		for iters := 0; iters < 50; iters++ {
+			var decided bool
+			if decided, got = pxa[2].Status(r.Seq); decided {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		batch, ok := got.(Batch)
+		if !ok || r.Index >= len(batch.Values) || batch.Values[r.Index] != v {
+			t.Fatalf("Status(%d) = %v, want: %v at index %d", r.Seq, got, v, r.Index)
+		}
+		seqs[r.Seq] = true
+	}
+	if len(seqs) >= nvalues {
+		t.Fatalf("%d values used %d instances, want: fewer", nvalues, len(seqs))
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestBatchKilled(t *testing.T) {
+	pxh := []string{port("batch-killed", 0), port("batch-killed", 1), port("batch-killed", 2)}
+	px := Make(pxh, 0, WithBatching(1, 0))
+	defer cleanup([]*Paxos{px})
+
+	fmt.Println("Test: Batching reports values given up on Kill ...")
+
+	// peers 1 and 2 never start, so the value cannot be decided.
+	result := px.Submit("v")
+	time.Sleep(100 * time.Millisecond)
+	px.Kill()
+	select {
+	case r := <-result:
+		if r.Err != ErrKilled {
+			t.Fatalf("Submit after Kill = %+v, want: Err %v", r, ErrKilled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Submit not answered after Kill")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosBatchingMencius(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("batching-mencius", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithMencius(), WithBatching(2, 50*time.Millisecond))
+	}
+
+	fmt.Println("Test: Batching in Mencius mode uses owned instances ...")
+
+	for i := 0; i < 4; i++ {
+		v := fmt.Sprintf("v%d", i)
+		select {
+		case r := <-pxa[1].Submit(v):
+			if r.Err != nil || r.Seq%npaxos != 1 {
+				t.Fatalf("Submit(%v) = %+v, want: an instance owned by peer 1", v, r)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Submit(%v) not decided", v)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,145 @@
+package gopaxos
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Every request carries the ID of the cluster it belongs to and the epoch of
+// the config its sender used. A peer rejects requests from another cluster,
+// e.g. a test cluster that reused one of our ports, and requests sent under
+// a config older than the one governing their instance, or for requests
+// about no instance, older than the current one. Catch-up requests are
+// exempt from the epoch check, so a stale peer can still learn.
+
+// ErrClusterMismatch is returned for a request from another cluster.
+var ErrClusterMismatch = errors.New("gopaxos: cluster mismatch")
+
+// ErrStaleEpoch is returned for a request sent under an outdated config.
+var ErrStaleEpoch = errors.New("gopaxos: stale config epoch")
+
+// WithClusterID sets the cluster ID, e.g. one made by NewClusterID. By
+// default it is derived from the initial peers, so every peer started with
+// the same peers agrees on it. A peer added by reconfiguration is started
+// with the grown peer list and WithInitialPeers: it derives the ID from the
+// initial peers too, starts from their config and, once it learns the
+// instance of the AddPeer change that added it, e.g. by WaitApplied on that
+// instance, replays that change like every other peer did. Until then it is
+// not a member of its own configs and its requests carry a stale epoch, so
+// it should catch up before it proposes.
+func WithClusterID(id string) Option {
+	if id == "" {
+		panic("invalid cluster id, want: non-empty")
+	}
+	return func(p *Paxos) {
+		p.clusterID = id
+	}
+}
+
+// NewClusterID returns a random UUID.
+func NewClusterID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return formatUUID(b)
+}
+
+// defaultClusterID derives a UUID from peers.
+func defaultClusterID(peers []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(peers, "\n")))
+	var b [16]byte
+	copy(b[:], sum[:])
+	b[6] = b[6]&0x0f | 0x80 // version 8, custom
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b)
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ClusterID returns the ID of this peer's cluster.
+func (p *Paxos) ClusterID() string {
+	return p.clusterID
+}
+
+// Admit checks a request for method before the Handler method runs: its
+// MAC, its cluster ID and its config epoch, see instanceMethods. It notes the sender's Forget
+// of an authentic request. Transports call it. A killed peer admits
+// nothing.
+func (h *Handler) Admit(method string, req *Request) error {
+	p := h.pxs
+	if p.ctx.Err() != nil {
+		return ErrKilled
+	}
+	if err := h.VerifyRequest(method, req); err != nil {
+		p.logWarn("request rejected", "method", method, "from", req.FromID, "err", err)
+		return err
+	}
+	if req.ClusterID != p.clusterID {
+		p.stats.clusterMismatches.Add(1)
+		p.logWarn("request rejected", "method", method, "from", req.FromID, "cluster", req.ClusterID)
+		return fmt.Errorf("%w: got %q, want: %q", ErrClusterMismatch, req.ClusterID, p.clusterID)
+	}
+	p.logDebug("request", "method", method, "from", req.FromID, "seq", req.Seq, "round", req.Round)
+	p.mu.Lock()
+	p.recordForget(req.FromID, req.Forget)
+	p.mu.Unlock()
+	if method == "Handler.OnReceiveCatchUp" {
+		return nil
+	}
+	p.mu.Lock()
+	epoch := p.currentConfig().Epoch
+	if instanceMethods[method] {
+		epoch = p.configs.at(req.Seq).Epoch
+	}
+	p.mu.Unlock()
+	if req.Epoch < epoch {
+		p.stats.staleEpochs.Add(1)
+		p.logWarn("request rejected", "method", method, "from", req.FromID, "epoch", req.Epoch)
+		return fmt.Errorf("%w: got %d, want: at least %d", ErrStaleEpoch, req.Epoch, epoch)
+	}
+	return nil
+}
+
+// instanceMethods are the methods whose req.Seq is a log instance. Admit
+// checks their epoch against the config of that instance rather than the
+// current one, so a peer that missed a reconfiguration can still run the
+// instances before it and so learn the change.
+var instanceMethods = map[string]bool{
+	"Handler.OnReceiveProposal":       true,
+	"Handler.OnReceiveAcceptance":     true,
+	"Handler.OnReceiveDecision":       true,
+	"Handler.OnReceiveFastPrepare":    true,
+	"Handler.OnReceiveFastAccept":     true,
+	"Handler.OnReceiveMenciusForward": true,
+	"Handler.OnReceiveCStructAppend":  true,
+	"Handler.OnReceiveCStructPrepare": true,
+	"Handler.OnReceiveCStructAccept":  true,
+	"Handler.OnReceiveCStructLearned": true,
+}
+
+// remoteError turns the message of an error a peer returned for
+// ErrClusterMismatch or ErrStaleEpoch back into one that wraps them, and
+// counts it.
+func (p *Paxos) remoteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	switch {
+	case errors.Is(err, ErrClusterMismatch) || errors.Is(err, ErrStaleEpoch):
+		return err
+	case strings.Contains(msg, ErrClusterMismatch.Error()):
+		p.stats.clusterMismatches.Add(1)
+		return fmt.Errorf("%w: rejected by peer: %s", ErrClusterMismatch, msg)
+	case strings.Contains(msg, ErrStaleEpoch.Error()):
+		p.stats.staleEpochs.Add(1)
+		return fmt.Errorf("%w: rejected by peer: %s", ErrStaleEpoch, msg)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package gopaxos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAdmitChecksClusterAndEpoch(t *testing.T) {
+	fmt.Println("Test: Requests from other clusters and stale configs are rejected ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p"}
+	pxs := newPaxos(peers, 0, WithAlpha(1))
+	h := NewHandler(pxs)
+	if other := newPaxos([]string{"a:1/p", "b:1/p", "d:1/p"}, 0); other.ClusterID() == pxs.ClusterID() {
+		t.Fatalf("clusters of different peers share id %s", pxs.ClusterID())
+	}
+	if again := newPaxos(peers, 1); again.ClusterID() != pxs.ClusterID() {
+		t.Fatalf("ClusterID() = %s, want: %s", again.ClusterID(), pxs.ClusterID())
+	}
+
+	method := "Handler.OnReceiveFastAccept"
+	if err := h.Admit(method, &Request{ClusterID: pxs.ClusterID()}); err != nil {
+		t.Fatalf("Admit() = %v, want: nil", err)
+	}
+	if err := h.Admit(method, &Request{ClusterID: NewClusterID()}); !errors.Is(err, ErrClusterMismatch) {
+		t.Fatalf("Admit() from another cluster = %v, want: %v", err, ErrClusterMismatch)
+	}
+
+	// config epoch 1 governs every instance from 1 on.
+	pxs.decide(0, ConfigChange{Op: AddLearner, Addr: "e:1/p"})
+	pxs.decide(1, "x")
+	if err := h.Admit(method, &Request{ClusterID: pxs.ClusterID(), Seq: 2, Epoch: 0}); !errors.Is(err, ErrStaleEpoch) {
+		t.Fatalf("Admit() in instance 2 at epoch 0 = %v, want: %v", err, ErrStaleEpoch)
+	}
+	// instance 0 is still governed by epoch 0.
+	if err := h.Admit(method, &Request{ClusterID: pxs.ClusterID(), Seq: 0, Epoch: 0}); err != nil {
+		t.Fatalf("Admit() in instance 0 at epoch 0 = %v, want: nil", err)
+	}
+	if err := h.Admit("Handler.OnReceiveReadIndex", &Request{ClusterID: pxs.ClusterID(), Epoch: 0}); !errors.Is(err, ErrStaleEpoch) {
+		t.Fatalf("Admit() of a read index at epoch 0 = %v, want: %v", err, ErrStaleEpoch)
+	}
+	if err := h.Admit("Handler.OnReceiveCatchUp", &Request{ClusterID: pxs.ClusterID(), Epoch: 0}); err != nil {
+		t.Fatalf("Admit() of a catch-up at epoch 0 = %v, want: nil", err)
+	}
+	if err := h.Admit(method, &Request{ClusterID: pxs.ClusterID(), Epoch: 1}); err != nil {
+		t.Fatalf("Admit() at epoch 1 = %v, want: nil", err)
+	}
+	if stats := pxs.RPCStats(); stats.ClusterMismatches != 1 || stats.StaleEpochs != 2 {
+		t.Fatalf("RPCStats() = %+v, want: 1 cluster mismatch, 2 stale epochs", stats)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosClusterMismatch(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("cluster-mismatch", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: Peers of another cluster are rejected ...")
+
+	// a peer of another cluster that reached one of our ports.
+	stranger := newPaxos(pxh, 1, WithClusterID(NewClusterID()))
+	defer stranger.Kill()
+	var resp Response
+	err := stranger.callPeer(context.Background(), pxh[0], "Handler.OnReceiveFastAccept",
+		&Request{FromID: 1, Seq: 0, Round: fastRound, Value: "stranger"}, &resp)
+	if !errors.Is(err, ErrClusterMismatch) {
+		t.Fatalf("call from another cluster = %v, want: %v", err, ErrClusterMismatch)
+	}
+	if n := stranger.RPCStats().ClusterMismatches; n != 1 {
+		t.Fatalf("stranger counted %d cluster mismatches, want: 1", n)
+	}
+
+	pxa[0].StartFast(0, "hello")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLaggingPeerDecidesAfterReconfigure(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("lagging-reconfigure", 3, WithAlpha(1))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: A peer that missed a reconfiguration still decides ...")
+
+	// peer 2 misses the change in instance 0 and the value in instance 1.
+	isolate(pxh, fts, []int{0, 1}, []int{2})
+	if err := pxa[0].Reconfigure(0, ConfigChange{Op: AddLearner, Addr: "127.0.0.1:1/x"}); err != nil {
+		t.Fatal(err)
+	}
+	pxa[0].Start(1, "a")
+	if err := waitN(pxa[:2], 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	isolate(pxh, fts, nil, nil)
+	// instance 2 waits for peer 2 to learn the config of epoch 1, which it
+	// does by running instances 0 and 1.
+	pxa[2].Start(2, "b")
+	pxa[2].Start(0, "z")
+	pxa[2].Start(1, "y")
+	if err := waitN(pxa, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, v := pxa[2].Status(1); v != "a" {
+		t.Fatalf("Status(1) on peer 2 = %v, want: a", v)
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,340 @@
+// Command paxosctl operates a gopaxos cluster through the admin interface
+// (Paxos.AdminHandler) of its peers, and can run a peer itself.
+//
+// Usage:
+//
+//	paxosctl start -config peer.yaml
+//	paxosctl status -admin URL[,URL...]
+//	paxosctl decided -admin URL [-from N] [-to N]
+//	paxosctl done -admin URL -seq N
+//	paxosctl add-member -admin URL -addr HOST:PORT/PATH [-learner] [-seq N]
+//	paxosctl remove-member -admin URL -id N [-seq N]
+//	paxosctl snapshot -admin URL [-from N] -o FILE
+//	paxosctl acquire-lease -admin URL
+//	paxosctl release-lease -admin URL
+//	paxosctl transfer-lease -admin URL -to URL
+//
+// URL is the base URL the admin handler of a peer is mounted on, e.g.
+// http://127.0.0.1:8081/admin. -admin defaults to $PAXOSCTL_ADMIN. start
+// takes the config file of paxosd and serves the admin handler under
+// /admin/ of its admin_listen address. add-member and remove-member return
+// once the change is in effect.
+//
+// done tells the peer that the application no longer needs the instances
+// up to -seq (Paxos.Done). Peers forget an instance only once every member
+// is done with it, so done prints Min() of the peer, below which it forgot
+// everything, and that may not have moved yet.
+//
+// acquire-lease asks for the lease on behalf of the peer; it cannot take
+// the lease from a live holder. release-lease makes the holder give its
+// lease up, and transfer-lease forces a leader transfer: it releases the
+// lease of the holder at -admin, then acquires one for the peer at -to.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos"
+	"github.com/yaoshengzhe/gopaxos/internal/daemon"
+)
+
+// errUsage reports a command line that paxosctl cannot run.
+var errUsage = errors.New("usage: paxosctl start|status|decided|done|add-member|remove-member|snapshot|acquire-lease|release-lease|transfer-lease [flags]")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "paxosctl:", err)
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs the command line args, writing its output to stdout.
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	admin := fs.String("admin", os.Getenv("PAXOSCTL_ADMIN"), "admin base URL of the peer, or a comma-separated list for status")
+	switch cmd {
+	case "start":
+		config := fs.String("config", "", "peer config file")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return start(*config)
+	case "status":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		return status(stdout, splitList(*admin))
+	}
+
+	var c *client
+	parse := func() error {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *admin == "" {
+			return fmt.Errorf("%w: -admin is required", errUsage)
+		}
+		c = newClient(*admin)
+		return nil
+	}
+	switch cmd {
+	case "decided":
+		from := fs.Int("from", -1, "first instance; if negative, up to 1000 instances before -to")
+		to := fs.Int("to", -1, "last instance; if negative, up to 1000 instances after -from, or Max()")
+		if err := parse(); err != nil {
+			return err
+		}
+		query := make([]string, 0, 2)
+		if *from >= 0 {
+			query = append(query, "from="+strconv.Itoa(*from))
+		}
+		if *to >= 0 {
+			query = append(query, "to="+strconv.Itoa(*to))
+		}
+		var decided []struct {
+			Seq   int             `json:"seq"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := c.get("/decided?"+strings.Join(query, "&"), &decided); err != nil {
+			return err
+		}
+		for _, d := range decided {
+			fmt.Fprintf(stdout, "%d\t%s\n", d.Seq, d.Value)
+		}
+		return nil
+	case "done":
+		seq := fs.Int("seq", -1, "instance at or below which the application is done")
+		if err := parse(); err != nil {
+			return err
+		}
+		if *seq < 0 {
+			return fmt.Errorf("%w: -seq is required", errUsage)
+		}
+		var s struct {
+			Min int `json:"min"`
+		}
+		if err := c.post("/done?seq="+strconv.Itoa(*seq), nil, &s); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s forgot the instances below %d\n", *admin, s.Min)
+		return nil
+	case "add-member", "remove-member":
+		addr := fs.String("addr", "", "HOST:PORT/PATH of the peer to add")
+		learner := fs.Bool("learner", false, "add the peer as a learner")
+		id := fs.Int("id", -1, "id of the peer to remove")
+		seq := fs.Int("seq", -1, "instance to propose the change in, Max()+1 if negative")
+		if err := parse(); err != nil {
+			return err
+		}
+		change := make(map[string]any)
+		if *seq >= 0 {
+			change["seq"] = *seq
+		}
+		switch {
+		case cmd == "remove-member" && *id >= 0:
+			change["op"], change["id"] = gopaxos.RemovePeer.String(), *id
+		case cmd == "add-member" && *addr != "" && *learner:
+			change["op"], change["addr"] = gopaxos.AddLearner.String(), *addr
+		case cmd == "add-member" && *addr != "":
+			change["op"], change["addr"] = gopaxos.AddPeer.String(), *addr
+		default:
+			return fmt.Errorf("%w: add-member needs -addr, remove-member needs -id", errUsage)
+		}
+		return c.post("/members", change, nil)
+	case "snapshot":
+		from := fs.Int("from", -1, "first instance, Min() if negative")
+		out := fs.String("o", "", "file to write the snapshot to")
+		if err := parse(); err != nil {
+			return err
+		}
+		if *out == "" {
+			return fmt.Errorf("%w: -o is required", errUsage)
+		}
+		path := "/snapshot"
+		if *from >= 0 {
+			path += "?from=" + strconv.Itoa(*from)
+		}
+		return c.download(path, *out)
+	case "acquire-lease":
+		if err := parse(); err != nil {
+			return err
+		}
+		if err := c.post("/leader", nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s holds the lease\n", *admin)
+		return nil
+	case "release-lease", "transfer-lease":
+		to := fs.String("to", "", "admin base URL of the peer to transfer the lease to")
+		if err := parse(); err != nil {
+			return err
+		}
+		if cmd == "transfer-lease" && *to == "" {
+			return fmt.Errorf("%w: -to is required", errUsage)
+		}
+		if err := c.post("/leader/release", nil, nil); err != nil {
+			return err
+		}
+		if cmd == "release-lease" {
+			fmt.Fprintf(stdout, "%s released the lease\n", *admin)
+			return nil
+		}
+		if err := newClient(*to).post("/leader", nil, nil); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%s holds the lease\n", *to)
+		return nil
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+}
+
+// status prints the status of every peer in admins.
+func status(stdout io.Writer, admins []string) error {
+	if len(admins) == 0 {
+		return fmt.Errorf("%w: -admin is required", errUsage)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADMIN\tID\tMIN\tMAX\tNEXT\tIN FLIGHT")
+	var failed error
+	for _, admin := range admins {
+		var s struct {
+			ID       int `json:"id"`
+			Min      int `json:"min"`
+			Max      int `json:"max"`
+			Next     int `json:"next"`
+			InFlight int `json:"in_flight"`
+		}
+		if err := newClient(admin).get("/status", &s); err != nil {
+			fmt.Fprintf(tw, "%s\t%v\n", admin, err)
+			failed = errors.New("some peers did not answer")
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", admin, s.ID, s.Min, s.Max, s.Next, s.InFlight)
+	}
+	tw.Flush()
+	return failed
+}
+
+// start runs the peer of the config file at path until SIGINT or SIGTERM,
+// like paxosd.
+func start(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: -config is required", errUsage)
+	}
+	c, err := daemon.Load(path)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return daemon.Run(ctx, c, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+}
+
+// client calls the admin handler mounted on base.
+type client struct {
+	base string
+	http *http.Client
+}
+
+func newClient(base string) *client {
+	return &client{base: strings.TrimSuffix(base, "/"), http: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *client) get(path string, v any) error {
+	return c.do("GET", path, nil, v)
+}
+
+func (c *client) post(path string, body, v any) error {
+	return c.do("POST", path, body, v)
+}
+
+func (c *client) do(method, path string, body, v any) error {
+	resp, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// download writes the answer to GET path to the file out.
+func (c *client) download(path, out string) error {
+	resp, err := c.send("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// send sends the request and fails unless it succeeded.
+func (c *client) send(method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
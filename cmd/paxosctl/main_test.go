@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos"
+)
+
+// singlePeer makes a one-peer cluster with ninst decided instances and
+// serves its admin handler.
+func singlePeer(t *testing.T, ninst int) (*gopaxos.Paxos, *httptest.Server) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	px := gopaxos.Make([]string{addr + "/paxosctl"}, 0)
+	t.Cleanup(px.Kill)
+	for seq := 0; seq < ninst; seq++ {
+		px.StartFast(seq, fmt.Sprint("v", seq))
+	}
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if decided, _ := px.Status(ninst - 1); decided {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("instance %d not decided", ninst-1)
+		}
+	}
+	srv := httptest.NewServer(px.AdminHandler())
+	t.Cleanup(srv.Close)
+	return px, srv
+}
+
+func TestPaxosctl(t *testing.T) {
+	fmt.Println("Test: paxosctl talks to the admin handler ...")
+
+	_, srv := singlePeer(t, 3)
+	var out bytes.Buffer
+
+	if err := run([]string{"status", "-admin", srv.URL}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ADMIN") || !strings.Contains(lines[1], srv.URL) {
+		t.Fatalf("status:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"decided", "-admin", srv.URL, "-from", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "1\t\"v1\"\n2\t\"v2\"\n"; got != want {
+		t.Fatalf("decided: %q, want: %q", got, want)
+	}
+
+	out.Reset()
+	if err := run([]string{"done", "-admin", srv.URL, "-seq", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	// the only member is done, so the instances are forgotten at once.
+	if got, want := out.String(), srv.URL+" forgot the instances below 2\n"; got != want {
+		t.Fatalf("done: %q, want: %q", got, want)
+	}
+
+	file := filepath.Join(t.TempDir(), "snap")
+	if err := run([]string{"snapshot", "-admin", srv.URL, "-o", file}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+		t.Fatalf("snapshot file: %v, %v", fi, err)
+	}
+
+	// reconfiguration and leases are not enabled on this peer.
+	if err := run([]string{"add-member", "-admin", srv.URL, "-addr", "127.0.0.1:1/x"}, &out); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Fatalf("add-member: %v, want: a conflict", err)
+	}
+	if err := run([]string{"acquire-lease", "-admin", srv.URL}, &out); err == nil {
+		t.Fatal("acquire-lease succeeded without leases")
+	}
+	if err := run([]string{"transfer-lease", "-admin", srv.URL, "-to", srv.URL}, &out); err == nil {
+		t.Fatal("transfer-lease succeeded without leases")
+	}
+
+	for _, args := range [][]string{nil, {"bogus"}, {"done", "-admin", srv.URL}, {"remove-member", "-admin", srv.URL}, {"transfer-lease", "-admin", srv.URL}} {
+		if err := run(args, &out); !errors.Is(err, errUsage) {
+			t.Fatalf("%q: %v, want: a usage error", args, err)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,70 @@
+// Command paxosd runs a gopaxos peer as a daemon.
+//
+// Usage:
+//
+//	paxosd -config /etc/paxosd/peer.yaml
+//
+// The config file is YAML, JSON or TOML, chosen by its extension:
+//
+//	id: 0
+//	peers: [10.0.0.1:7000/paxos, 10.0.0.2:7000/paxos, 10.0.0.3:7000/paxos]
+//	listen: :8080
+//	admin_listen: 127.0.0.1:8081
+//	data_dir: /var/lib/paxosd
+//	transport: grpc
+//	tls: {cert_file: peer.pem, key_file: peer-key.pem, ca_file: ca.pem}
+//	rpc_timeout: 2s
+//
+// The HTTP server on listen serves the client API under /v1/ (POST
+// /v1/propose, GET /v1/log/{seq}, GET /v1/log) and Prometheus metrics on
+// /metrics. The one on admin_listen serves the admin interface that
+// paxosctl talks to under /admin/; it has no access control, so bind it
+// to an address only operators can reach. SIGINT or SIGTERM shuts the
+// peer down gracefully: in-flight HTTP requests finish, the decided log
+// is saved to data_dir, and the peer is killed.
+//
+// With data_dir set, the promises and votes of the peer are synced to
+// data_dir before it answers a prepare or accept and restored on start, so
+// a crashed peer comes back with them. The decided log is only saved on
+// graceful shutdown; a crashed peer learns the rest from the others.
+// Without data_dir, a restarted peer comes back without its acceptor
+// state.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yaoshengzhe/gopaxos/internal/daemon"
+)
+
+func main() {
+	path := flag.String("config", "", "config file, .yaml, .yml, .json or .toml")
+	flag.Parse()
+	if *path == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c, err := daemon.Load(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "paxosd:", err)
+		os.Exit(1)
+	}
+
+	level := slog.LevelInfo
+	if c.Debug {
+		level = slog.LevelDebug
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := daemon.Run(ctx, c, log); err != nil {
+		log.Error("paxosd", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,331 @@
+package gopaxos
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sort"
+)
+
+// Membership changes are decided through the log like any other value. A
+// ConfigChange decided in instance s governs instances s+alpha and later, so
+// the configuration of instance seq is known once every instance <= seq-alpha
+// has been decided. alpha == 1 is the classic stop-sign handoff: a change must
+// be decided before the next instance may start. Larger windows let up to
+// alpha instances run concurrently while a change is in flight.
+
+// ConfigOp is the kind of membership change carried by a ConfigChange.
+type ConfigOp int
+
+const (
+	// AddPeer appends Addr as a new peer; its id is the next free index.
+	// Start the new peer with WithInitialPeers.
+	AddPeer ConfigOp = iota + 1
+	// RemovePeer retires peer ID. Ids are never reused, so FromID stays stable.
+	RemovePeer
+	// ReplacePeer moves peer ID to Addr, e.g. after replacing a machine.
+	ReplacePeer
+	// AddLearner appends Addr as a new learner that does not vote.
+	AddLearner
+	// PromoteLearner turns learner ID into a voter.
+	PromoteLearner
+)
+
+func (op ConfigOp) String() string {
+	switch op {
+	case AddPeer:
+		return "AddPeer"
+	case RemovePeer:
+		return "RemovePeer"
+	case ReplacePeer:
+		return "ReplacePeer"
+	case AddLearner:
+		return "AddLearner"
+	case PromoteLearner:
+		return "PromoteLearner"
+	}
+	return fmt.Sprintf("ConfigOp(%d)", int(op))
+}
+
+// ParseConfigOp returns the ConfigOp whose String is s.
+func ParseConfigOp(s string) (ConfigOp, error) {
+	for op := AddPeer; op <= PromoteLearner; op++ {
+		if op.String() == s {
+			return op, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown config op %q", s)
+}
+
+// ConfigChange is a special log entry. Once decided, it changes the peers of
+// every instance from its seq+alpha on.
+type ConfigChange struct {
+	Op   ConfigOp
+	ID   int    // peer being removed, replaced or promoted
+	Addr string // ${HOSTNAME}:${PORT}/${RPC_PATH} of an added or replaced peer
+}
+
+// Config is the membership that governs instances from Start on.
+type Config struct {
+	Epoch int      // number of changes applied since the initial peers
+	Start int      // first instance governed by this config
+	Peers []string // indexed by peer id, "" once a peer has been removed
+
+	// Learners receive decided values but are excluded from quorums.
+	Learners []int
+
+	// Flexible Paxos quorum sizes, 0 means a majority of the voters. Any
+	// prepare quorum must intersect any accept quorum, so the only
+	// requirement is PrepareQuorum + AcceptQuorum > len(Voters()).
+	PrepareQuorum int
+	AcceptQuorum  int
+
+	// Quorums overrides the quorum sizes above when set.
+	Quorums QuorumSystem
+}
+
+// Members returns the ids of the peers that have not been removed.
+func (c Config) Members() []int {
+	var ids []int
+	for id, addr := range c.Peers {
+		if addr != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Voters returns the ids of the members that are not learners.
+func (c Config) Voters() []int {
+	var ids []int
+	for _, id := range c.Members() {
+		if !c.IsLearner(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// IsLearner reports whether peer id is a learner.
+func (c Config) IsLearner(id int) bool {
+	for _, l := range c.Learners {
+		if l == id {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVoter reports whether peer id is a member that counts toward quorums.
+func (c Config) IsVoter(id int) bool {
+	return id >= 0 && id < len(c.Peers) && c.Peers[id] != "" && !c.IsLearner(id)
+}
+
+// QuorumSize returns the number of voters that form a majority.
+func (c Config) QuorumSize() int {
+	return len(c.Voters())/2 + 1
+}
+
+// PrepareQuorumSize returns the number of promises a proposer needs in phase 1.
+func (c Config) PrepareQuorumSize() int {
+	if c.PrepareQuorum == 0 {
+		return c.QuorumSize()
+	}
+	return c.PrepareQuorum
+}
+
+// AcceptQuorumSize returns the number of accepts a proposer needs in phase 2.
+func (c Config) AcceptQuorumSize() int {
+	if c.AcceptQuorum == 0 {
+		return c.QuorumSize()
+	}
+	return c.AcceptQuorum
+}
+
+// QuorumSystem returns the quorum system the proposer consults for c.
+func (c Config) QuorumSystem() QuorumSystem {
+	if c.Quorums != nil {
+		return c.Quorums
+	}
+	if c.PrepareQuorum == 0 && c.AcceptQuorum == 0 {
+		return Majority{}
+	}
+	return Flexible{Prepare: c.PrepareQuorumSize(), Accept: c.AcceptQuorumSize()}
+}
+
+// IsQuorum reports whether acks, a set of peer ids, form a quorum for phase.
+func (c Config) IsQuorum(phase Phase, acks []int) bool {
+	return c.QuorumSystem().IsQuorum(phase, c.Voters(), acks)
+}
+
+// check fails if c has no voter, names a learner that is not a member or has
+// quorums that do not intersect.
+func (c Config) check() error {
+	for _, id := range c.Learners {
+		if id < 0 || id >= len(c.Peers) || c.Peers[id] == "" {
+			return fmt.Errorf("learner %d is not a member", id)
+		}
+	}
+	n := len(c.Voters())
+	if n == 0 {
+		return fmt.Errorf("no voter left")
+	}
+	q1, q2 := c.PrepareQuorumSize(), c.AcceptQuorumSize()
+	if q1 < 1 || q1 > n || q2 < 1 || q2 > n {
+		return fmt.Errorf("quorum sizes q1: %d, q2: %d, want: within [1, %d]", q1, q2, n)
+	}
+	if q1+q2 <= n {
+		return fmt.Errorf("quorum sizes q1: %d, q2: %d, want: q1 + q2 > %d", q1, q2, n)
+	}
+	if c.Quorums != nil {
+		if c.PrepareQuorum != 0 || c.AcceptQuorum != 0 {
+			return fmt.Errorf("both quorum sizes and a quorum system are set")
+		}
+		return checkIntersection(c.Quorums, c.Voters())
+	}
+	return nil
+}
+
+// apply returns the config that results from ch. It never modifies c.
+func (c Config) apply(ch ConfigChange) (Config, error) {
+	next := c
+	next.Epoch++
+	next.Peers = append([]string(nil), c.Peers...)
+	next.Learners = append([]int(nil), c.Learners...)
+
+	switch ch.Op {
+	case AddPeer, AddLearner:
+		if err := c.checkNewPeer(ch.Addr); err != nil {
+			return c, err
+		}
+		next.Peers = append(next.Peers, ch.Addr)
+		if ch.Op == AddLearner {
+			next.Learners = append(next.Learners, len(next.Peers)-1)
+		}
+	case RemovePeer:
+		if ch.ID < 0 || ch.ID >= len(c.Peers) || c.Peers[ch.ID] == "" {
+			return c, fmt.Errorf("peer %d is not a member", ch.ID)
+		}
+		next.Peers[ch.ID] = ""
+		next.Learners = removeID(next.Learners, ch.ID)
+	case PromoteLearner:
+		if !c.IsLearner(ch.ID) {
+			return c, fmt.Errorf("peer %d is not a learner", ch.ID)
+		}
+		next.Learners = removeID(next.Learners, ch.ID)
+	case ReplacePeer:
+		if ch.ID < 0 || ch.ID >= len(c.Peers) || c.Peers[ch.ID] == "" {
+			return c, fmt.Errorf("peer %d is not a member", ch.ID)
+		}
+		if err := c.checkNewPeer(ch.Addr); err != nil {
+			return c, err
+		}
+		next.Peers[ch.ID] = ch.Addr
+	default:
+		return c, fmt.Errorf("unknown config op: %v", ch.Op)
+	}
+	if err := next.check(); err != nil {
+		return c, err
+	}
+	return next, nil
+}
+
+// checkNewPeer fails unless addr is a valid peer address that is not a
+// member yet.
+func (c Config) checkNewPeer(addr string) error {
+	if err := checkPeerAddr(addr); err != nil {
+		return err
+	}
+	for _, member := range c.Peers {
+		if member == addr {
+			return fmt.Errorf("peer %s is already a member", addr)
+		}
+	}
+	return nil
+}
+
+func removeID(ids []int, id int) []int {
+	var out []int
+	for _, i := range ids {
+		if i != id {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// configHistory replays decided ConfigChanges in seq order, so every peer
+// derives the same config for an instance no matter in which order the
+// changes were learned.
+type configHistory struct {
+	alpha   int
+	initial Config
+	changes map[int]ConfigChange
+	configs []Config // initial followed by every successfully applied change
+}
+
+func newConfigHistory(peers []string, learners []int, q1, q2 int, qs QuorumSystem, alpha int) *configHistory {
+	initial := Config{
+		Peers:         append([]string(nil), peers...),
+		Learners:      append([]int(nil), learners...),
+		PrepareQuorum: q1,
+		AcceptQuorum:  q2,
+		Quorums:       qs,
+	}
+	return &configHistory{
+		alpha:   alpha,
+		initial: initial,
+		changes: make(map[int]ConfigChange),
+		configs: []Config{initial},
+	}
+}
+
+// record notes that ch was decided in instance seq.
+func (h *configHistory) record(seq int, ch ConfigChange) {
+	if _, ok := h.changes[seq]; ok {
+		return
+	}
+	h.changes[seq] = ch
+
+	var seqs []int
+	for s := range h.changes {
+		seqs = append(seqs, s)
+	}
+	sort.Ints(seqs)
+
+	h.configs = []Config{h.initial}
+	cur := h.initial
+	for _, s := range seqs {
+		next, err := cur.apply(h.changes[s])
+		if err != nil {
+			// Invalid changes are decided like any other value, but every
+			// peer skips them the same way.
+			continue
+		}
+		next.Start = s + h.alpha
+		h.configs = append(h.configs, next)
+		cur = next
+	}
+}
+
+// at returns the config that governs instance seq, assuming every change at
+// or below seq-alpha has been recorded.
+func (h *configHistory) at(seq int) Config {
+	i := sort.Search(len(h.configs), func(i int) bool { return h.configs[i].Start > seq })
+	return h.configs[i-1]
+}
+
+// latest returns the config produced by every change recorded so far.
+func (h *configHistory) latest() Config {
+	return h.configs[len(h.configs)-1]
+}
+
+func checkPeerAddr(addr string) error {
+	if _, _, err := splitPeerAddr(addr); err != nil {
+		return err
+	}
+	return nil
+}
+
+func init() {
+	gob.Register(ConfigChange{})
+}
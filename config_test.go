@@ -0,0 +1,459 @@
+package gopaxos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConfigChangesTakeEffectAfterAlpha(t *testing.T) {
+	fmt.Println("Test: Config changes take effect after alpha ...")
+
+	h := newConfigHistory([]string{"a:1/p", "b:1/p", "c:1/p"}, nil, 0, 0, nil, 3)
+	h.record(4, ConfigChange{Op: AddPeer, Addr: "d:1/p"})
+
+	for seq := 0; seq < 7; seq++ {
+		if got := len(h.at(seq).Peers); got != 3 {
+			t.Fatalf("len(at(%d).Peers) = %d, want: 3", seq, got)
+		}
+	}
+	c := h.at(7)
+	if c.Epoch != 1 || c.Start != 7 {
+		t.Fatalf("at(7) = %+v, want: epoch 1 starting at 7", c)
+	}
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(c.Members(), want) {
+		t.Fatalf("at(7).Members() = %v, want: %v", c.Members(), want)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestConfigChangesReplayInSeqOrder(t *testing.T) {
+	fmt.Println("Test: Config changes replay in seq order ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p"}
+	changes := map[int]ConfigChange{
+		2: {Op: ReplacePeer, ID: 1, Addr: "b:2/p"},
+		5: {Op: RemovePeer, ID: 0},
+		// decided after peer 0 is gone, so every peer must skip it
+		9: {Op: ReplacePeer, ID: 0, Addr: "a:2/p"},
+	}
+
+	inOrder := newConfigHistory(peers, nil, 0, 0, nil, 1)
+	for _, seq := range []int{2, 5, 9} {
+		inOrder.record(seq, changes[seq])
+	}
+	reversed := newConfigHistory(peers, nil, 0, 0, nil, 1)
+	for _, seq := range []int{9, 5, 2} {
+		reversed.record(seq, changes[seq])
+	}
+
+	if !reflect.DeepEqual(inOrder.configs, reversed.configs) {
+		t.Fatalf("configs differ: %+v vs %+v", inOrder.configs, reversed.configs)
+	}
+	want := Config{Epoch: 2, Start: 6, Peers: []string{"", "b:2/p", "c:1/p"}}
+	if got := inOrder.at(100); !reflect.DeepEqual(got, want) {
+		t.Fatalf("at(100) = %+v, want: %+v", got, want)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestConfigApplyRejectsInvalidChanges(t *testing.T) {
+	fmt.Println("Test: Invalid config changes are rejected ...")
+
+	c := Config{Peers: []string{"a:1/p", ""}}
+	for _, ch := range []ConfigChange{
+		{Op: AddPeer, Addr: "a:1/p"},
+		{Op: AddPeer, Addr: "no-rpc-path"},
+		{Op: RemovePeer, ID: 1},
+		{Op: RemovePeer, ID: 0},
+		{Op: ReplacePeer, ID: 5, Addr: "b:1/p"},
+		{Op: ReplacePeer, ID: 0, Addr: "a:1/p"},
+		{Op: ConfigOp(42)},
+	} {
+		if _, err := c.apply(ch); err == nil {
+			t.Fatalf("apply(%+v) succeeded, want: error", ch)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosConfigWaitsForWindow(t *testing.T) {
+	fmt.Println("Test: Config unknown until window is decided ...")
+
+	pxs := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 0, WithAlpha(2))
+
+	if _, ok := pxs.Config(2); ok {
+		t.Fatalf("Config(2) known before instance 0 is decided")
+	}
+	pxs.decide(0, ConfigChange{Op: RemovePeer, ID: 2})
+	c, ok := pxs.Config(2)
+	if !ok {
+		t.Fatalf("Config(2) unknown after instance 0 is decided")
+	}
+	if c.QuorumSize() != 2 || len(c.Members()) != 2 {
+		t.Fatalf("Config(2) = %+v, want: 2 members", c)
+	}
+	if c, _ := pxs.Config(1); len(c.Members()) != 3 {
+		t.Fatalf("Config(1) = %+v, want: initial peers", c)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosReconfigure(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("reconfigure", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithAlpha(1))
+	}
+
+	fmt.Println("Test: Membership changes are decided with classic rounds ...")
+
+	pxa[0].Start(0, "a")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if err := pxa[1].Reconfigure(1, ConfigChange{Op: RemovePeer, ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitN(pxa, 1, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	c, ok := pxa[0].Config(2)
+	if !ok || c.IsVoter(2) || c.Epoch != 1 {
+		t.Fatalf("Config(2) = %+v, %v, want: epoch 1 without peer 2", c, ok)
+	}
+
+	// the removed peer is gone for good, the other two still decide.
+	pxa[2].Kill()
+	pxa[0].Start(2, "b")
+	if err := waitN(pxa[:2], 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosAddedPeerProposes(t *testing.T) {
+	npaxos := 4
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("added-peer", i)
+	}
+	for i := 0; i < 3; i++ {
+		pxa[i] = Make(pxh[:3], i, WithAlpha(1))
+	}
+	pxa[3] = Make(pxh, 3, WithAlpha(1), WithInitialPeers(3))
+
+	fmt.Println("Test: A peer added by reconfiguration proposes ...")
+
+	if pxa[3].ClusterID() != pxa[0].ClusterID() {
+		t.Fatalf("ClusterID() = %s, want: %s", pxa[3].ClusterID(), pxa[0].ClusterID())
+	}
+	if err := pxa[0].Reconfigure(0, ConfigChange{Op: AddPeer, Addr: pxh[3]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitN(pxa[:3], 0, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new peer learns the change that added it, then proposes.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pxa[3].WaitApplied(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := pxa[0].Config(1)
+	if c, ok := pxa[3].Config(1); !ok || !reflect.DeepEqual(c, want) || !c.IsVoter(3) {
+		t.Fatalf("Config(1) = %+v, %v, want: %+v", c, ok, want)
+	}
+	pxa[3].Start(1, "new")
+	if err := waitN(pxa, 1, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if decided, v := pxa[0].Status(1); !decided || v != "new" {
+		t.Fatalf("Status(1) = %v, %v, want: true, new", decided, v)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestConfigLearnersDoNotVote(t *testing.T) {
+	fmt.Println("Test: Learners are excluded from quorums ...")
+
+	h := newConfigHistory([]string{"a:1/p", "b:1/p", "c:1/p", "d:1/p"}, []int{3}, 0, 0, nil, 1)
+	c := h.at(0)
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(c.Voters(), want) {
+		t.Fatalf("Voters() = %v, want: %v", c.Voters(), want)
+	}
+	if c.QuorumSize() != 2 {
+		t.Fatalf("QuorumSize() = %d, want: 2", c.QuorumSize())
+	}
+
+	h.record(0, ConfigChange{Op: AddLearner, Addr: "e:1/p"})
+	h.record(1, ConfigChange{Op: PromoteLearner, ID: 3})
+	c = h.at(2)
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(c.Voters(), want) {
+		t.Fatalf("Voters() = %v, want: %v", c.Voters(), want)
+	}
+	if !c.IsLearner(4) || c.IsVoter(4) {
+		t.Fatalf("peer 4 should be a learner in %+v", c)
+	}
+	if c.QuorumSize() != 3 {
+		t.Fatalf("QuorumSize() = %d, want: 3", c.QuorumSize())
+	}
+
+	if _, err := c.apply(ConfigChange{Op: PromoteLearner, ID: 0}); err == nil {
+		t.Fatalf("promoting a voter succeeded, want: error")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLearnerRejectsProposals(t *testing.T) {
+	fmt.Println("Test: Learner rejects proposals ...")
+
+	pxs := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 2, WithLearners(2))
+	h := NewHandler(pxs)
+	if err := h.OnReceiveProposal(&Request{FromID: 0, Seq: 0}, &Response{}); err == nil {
+		t.Fatalf("learner accepted a proposal")
+	}
+	if err := h.OnReceiveDecision(&Request{FromID: 0, Seq: 0, Value: "x"}, &Response{}); err != nil {
+		t.Fatalf("learner rejected a decision: %v", err)
+	}
+	if decided, v := pxs.Status(0); !decided || v != "x" {
+		t.Fatalf("Status(0) = %v, %v, want: true, x", decided, v)
+	}
+	var resp Response
+	h.OnReceiveCatchUp(&Request{FromID: 0, Seq: 0}, &resp)
+	if !resp.Decided || resp.Value != "x" {
+		t.Fatalf("OnReceiveCatchUp(0) = %+v, want: decided x", resp)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLearnerCatchesUp(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("learner-catches-up", 4, WithLearners(3))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: Learner hears decisions and catches up on missed ones ...")
+
+	pxa[0].Start(0, "hello")
+	if err := waitN(pxa, 0, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// the learner misses the broadcast of instance 1.
+	isolate(pxh, fts, []int{0, 1, 2}, []int{3})
+	pxa[1].Start(1, "missed")
+	if err := waitN(pxa[:3], 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if decided, _ := pxa[3].Status(1); decided {
+		t.Fatalf("isolated learner learned instance 1")
+	}
+
+	isolate(pxh, fts, nil, nil)
+	if !pxa[3].CatchUp(1) {
+		t.Fatalf("learner did not catch up on instance 1")
+	}
+	if decided, v := pxa[3].Status(1); !decided || v != "missed" {
+		t.Fatalf("Status(1) = %v, %v, want: true, missed", decided, v)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestConfigFlexibleQuorumsIntersect(t *testing.T) {
+	fmt.Println("Test: Flexible quorums intersect ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p", "d:1/p", "e:1/p"}
+	pxs := newPaxos(peers, 0, WithQuorums(4, 2))
+	c, _ := pxs.Config(0)
+	if c.PrepareQuorumSize() != 4 || c.AcceptQuorumSize() != 2 {
+		t.Fatalf("quorum sizes = %d, %d, want: 4, 2", c.PrepareQuorumSize(), c.AcceptQuorumSize())
+	}
+
+	// every prepare quorum shares an acceptor with every accept quorum, which
+	// is what keeps a new leader from missing a chosen value.
+	n := len(peers)
+	for q1 := 0; q1 < 1<<uint(n); q1++ {
+		if bits(q1) != c.PrepareQuorumSize() {
+			continue
+		}
+		for q2 := 0; q2 < 1<<uint(n); q2++ {
+			if bits(q2) == c.AcceptQuorumSize() && q1&q2 == 0 {
+				t.Fatalf("prepare quorum %05b misses accept quorum %05b", q1, q2)
+			}
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+// filterTransport drops the calls its filter blocks, to partition peers.
+type filterTransport struct {
+	Transport
+	mu      sync.Mutex
+	blocked func(peer, method string) bool
+}
+
+func (t *filterTransport) Call(ctx context.Context, peer, method string, req *Request, resp *Response) error {
+	t.mu.Lock()
+	blocked := t.blocked != nil && t.blocked(peer, method)
+	t.mu.Unlock()
+	if blocked {
+		return errors.New("partitioned")
+	}
+	return t.Transport.Call(ctx, peer, method, req, resp)
+}
+
+func (t *filterTransport) block(f func(peer, method string) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.blocked = f
+}
+
+// makeFiltered makes npaxos peers whose calls go through a filterTransport.
+func makeFiltered(tag string, npaxos int, opts ...Option) ([]*Paxos, []string, []*filterTransport) {
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	fts := make([]*filterTransport, npaxos)
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port(tag, i)
+	}
+	for i := 0; i < npaxos; i++ {
+		fts[i] = &filterTransport{Transport: newRPCTransport()}
+		pxa[i] = Make(pxh, i, append(opts, WithTransport(fts[i]))...)
+	}
+	return pxa, pxh, fts
+}
+
+// isolate blocks every call between the peers of a and those of b.
+func isolate(pxh []string, fts []*filterTransport, a, b []int) {
+	side := make(map[string]int)
+	for _, id := range a {
+		side[pxh[id]] = 1
+	}
+	for _, id := range b {
+		side[pxh[id]] = 2
+	}
+	for id, ft := range fts {
+		from := side[pxh[id]]
+		ft.block(func(peer, method string) bool {
+			return from != 0 && side[peer] != 0 && side[peer] != from
+		})
+	}
+}
+
+func TestGoPaxosFlexibleNoDecisionIfPartitioned(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("flexible-partitioned", 5, WithQuorums(4, 2))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: Flexible quorums, no decision without a prepare quorum ...")
+
+	// neither side holds 4 voters, although both hold an accept quorum.
+	isolate(pxh, fts, []int{0, 1}, []int{2, 3, 4})
+	pxa[0].Start(0, "a")
+	pxa[4].Start(0, "b")
+	time.Sleep(time.Second)
+	if n, err := ndecided(pxa, 0); err != nil || n != 0 {
+		t.Fatalf("%d peers decided (%v), want: none", n, err)
+	}
+
+	// once 4 voters meet again, one value is decided everywhere.
+	isolate(pxh, fts, []int{0}, []int{1, 2, 3, 4})
+	if err := waitN(pxa[1:], 0, 4); err != nil {
+		t.Fatal(err)
+	}
+	isolate(pxh, fts, nil, nil)
+	if err := waitN(pxa, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosFlexibleChosenValueSurvives(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("flexible-survives", 5, WithQuorums(4, 2))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: Flexible quorums, a value chosen by 2 voters survives ...")
+
+	// peer 0 collects 4 promises, but only peers 0 and 1 hear its accept
+	// and its decision: "a" is chosen by the smallest accept quorum.
+	others := map[string]bool{pxh[2]: true, pxh[3]: true, pxh[4]: true}
+	fts[0].block(func(peer, method string) bool {
+		return others[peer] && method != "Handler.OnReceiveFastPrepare"
+	})
+	pxa[0].Start(0, "a")
+	if err := waitN(pxa[:1], 0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// peer 0 goes away; any prepare quorum of the rest includes peer 1.
+	isolate(pxh, fts, []int{0}, []int{1, 2, 3, 4})
+	pxa[4].Start(0, "b")
+	if err := waitN(pxa[1:], 0, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, v := pxa[4].Status(0); v != "a" {
+		t.Fatalf("Status(0) = %v on peer 4, want: the chosen value a", v)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestConfigFlexibleQuorumsValidated(t *testing.T) {
+	fmt.Println("Test: Flexible quorums are validated ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p", "d:1/p", "e:1/p"}
+	for _, q := range [][2]int{{3, 2}, {2, 2}, {6, 1}, {-1, 5}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("newPaxos with quorums %v did not panic", q)
+				}
+			}()
+			newPaxos(peers, 0, WithQuorums(q[0], q[1]))
+		}()
+	}
+
+	// adding a sixth voter would make 4 + 2 quorums disjoint.
+	h := newConfigHistory(peers, nil, 4, 2, nil, 1)
+	h.record(0, ConfigChange{Op: AddPeer, Addr: "f:1/p"})
+	if got := len(h.at(1).Voters()); got != 5 {
+		t.Fatalf("len(Voters()) = %d, want: 5", got)
+	}
+	h.record(1, ConfigChange{Op: AddLearner, Addr: "f:1/p"})
+	if got := len(h.at(2).Members()); got != 6 {
+		t.Fatalf("len(Members()) = %d, want: 6", got)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func bits(x int) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
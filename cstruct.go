@@ -0,0 +1,118 @@
+package gopaxos
+
+import "encoding/gob"
+
+// Commute reports whether applying a then b has the same effect as applying
+// b then a, e.g. two increments of a counter or two adds to a set.
+type Commute func(a, b Value) bool
+
+// CStruct is a command history: a sequence of distinct commands in which the
+// order of commuting commands does not matter. Two histories are equivalent
+// if one can be turned into the other by swapping adjacent commuting
+// commands. Commands must be comparable with ==.
+type CStruct []Value
+
+func (cs CStruct) contains(c Value) bool {
+	for _, x := range cs {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Append returns cs followed by c, or cs itself if it already contains c.
+func (cs CStruct) Append(c Value) CStruct {
+	if cs.contains(c) {
+		return cs
+	}
+	return append(append(CStruct(nil), cs...), c)
+}
+
+// removeFirst removes c from cs if c can be moved to the front of cs, that
+// is, if it commutes with every command before it.
+func (cs CStruct) removeFirst(c Value, commute Commute) (CStruct, bool) {
+	for i, x := range cs {
+		if x == c {
+			return append(append(CStruct(nil), cs[:i]...), cs[i+1:]...), true
+		}
+		if !commute(x, c) {
+			return cs, false
+		}
+	}
+	return cs, false
+}
+
+// IsPrefix reports whether other is equivalent to cs followed by more
+// commands.
+func (cs CStruct) IsPrefix(other CStruct, commute Commute) bool {
+	rest := other
+	for _, c := range cs {
+		var ok bool
+		if rest, ok = rest.removeFirst(c, commute); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether cs and other are equivalent histories.
+func (cs CStruct) Equal(other CStruct, commute Commute) bool {
+	return len(cs) == len(other) && cs.IsPrefix(other, commute)
+}
+
+// glb returns the longest history that is a prefix of both a and b.
+func glb(a, b CStruct, commute Commute) CStruct {
+	var common CStruct
+	for {
+		found := false
+		for _, c := range a {
+			ra, okA := a.removeFirst(c, commute)
+			rb, okB := b.removeFirst(c, commute)
+			if okA && okB {
+				common, a, b = append(common, c), ra, rb
+				found = true
+				break
+			}
+		}
+		if !found {
+			return common
+		}
+	}
+}
+
+// glbAll returns the longest common prefix of every history in css.
+func glbAll(css []CStruct, commute Commute) CStruct {
+	if len(css) == 0 {
+		return nil
+	}
+	common := css[0]
+	for _, cs := range css[1:] {
+		common = glb(common, cs, commute)
+	}
+	return common
+}
+
+// lub returns the shortest history that both a and b are prefixes of, or
+// false if a and b are incompatible, i.e. order two non-commuting commands
+// differently.
+func lub(a, b CStruct, commute Commute) (CStruct, bool) {
+	common := glb(a, b, commute)
+	ra, rb := a, b
+	for _, c := range common {
+		ra, _ = ra.removeFirst(c, commute)
+		rb, _ = rb.removeFirst(c, commute)
+	}
+	for _, x := range ra {
+		for _, y := range rb {
+			if x == y || !commute(x, y) {
+				return nil, false
+			}
+		}
+	}
+	return append(append(CStruct(nil), a...), rb...), true
+}
+
+func init() {
+	gob.Register(CStruct{})
+}
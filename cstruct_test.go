@@ -0,0 +1,145 @@
+package gopaxos
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// incs commute with each other, anything else commutes with nothing.
+func commuteIncs(a, b Value) bool {
+	return strings.HasPrefix(a.(string), "inc") && strings.HasPrefix(b.(string), "inc")
+}
+
+func TestCStructPrefix(t *testing.T) {
+	fmt.Println("Test: CStruct prefixes ...")
+
+	tests := []struct {
+		a, b CStruct
+		want bool
+	}{
+		{CStruct{"inc1"}, CStruct{"inc2", "inc1"}, true},
+		{CStruct{"set"}, CStruct{"inc1", "set"}, false},
+		{CStruct{"inc1", "set"}, CStruct{"inc1", "set", "inc2"}, true},
+		{CStruct{"inc2", "set"}, CStruct{"inc1", "inc2", "set"}, false},
+		{nil, CStruct{"set"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.a.IsPrefix(tt.b, commuteIncs); got != tt.want {
+			t.Fatalf("%v.IsPrefix(%v) = %v, want: %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if !(CStruct{"inc1", "inc2", "set"}).Equal(CStruct{"inc2", "inc1", "set"}, commuteIncs) {
+		t.Fatalf("reordered incs are not equal")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestCStructBounds(t *testing.T) {
+	fmt.Println("Test: CStruct glb and lub ...")
+
+	a := CStruct{"inc1", "inc2", "set"}
+	b := CStruct{"inc2", "inc1", "inc3"}
+	if got, want := glb(a, b, commuteIncs), (CStruct{"inc1", "inc2"}); !got.Equal(want, commuteIncs) {
+		t.Fatalf("glb(%v, %v) = %v, want: %v", a, b, got, want)
+	}
+	// set and inc3 do not commute, so no history extends both.
+	if _, ok := lub(a, b, commuteIncs); ok {
+		t.Fatalf("lub(%v, %v) exists, want: incompatible", a, b)
+	}
+
+	c := CStruct{"inc3", "inc1"}
+	d := CStruct{"inc1", "inc2"}
+	up, ok := lub(c, d, commuteIncs)
+	if !ok || !c.IsPrefix(up, commuteIncs) || !d.IsPrefix(up, commuteIncs) || len(up) != 3 {
+		t.Fatalf("lub(%v, %v) = %v, %v, want: inc1 inc2 inc3", c, d, up, ok)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosGeneralizedCommutingCommands(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("generalized-commuting-commands", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithCommute(commuteIncs))
+	}
+
+	fmt.Println("Test: Generalized Paxos, commuting and conflicting commands ...")
+
+	var cmds []string
+	for i := 0; i < npaxos; i++ {
+		for j := 0; j < 3; j++ {
+			cmd := fmt.Sprintf("inc-%d-%d", i, j)
+			cmds = append(cmds, cmd)
+			pxa[i].AppendCommand(0, cmd)
+		}
+		cmd := fmt.Sprintf("set-%d", i)
+		cmds = append(cmds, cmd)
+		pxa[i].AppendCommand(0, cmd)
+	}
+
+	var learned []CStruct
+	for iters := 0; iters < 50; iters++ {
+		learned = learned[:0]
+		for i := 0; i < npaxos; i++ {
+			_, v := pxa[i].Status(0)
+			learned = append(learned, toCStruct(v))
+		}
+		complete := true
+		for _, cs := range learned {
+			if len(cs) != len(cmds) {
+				complete = false
+			}
+		}
+		if complete {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	for i, cs := range learned {
+		if len(cs) != len(cmds) {
+			t.Fatalf("peer %d learned %v, want: all of %v", i, cs, cmds)
+		}
+		if !cs.Equal(learned[0], commuteIncs) {
+			t.Fatalf("peer %d learned %v, peer 0 learned %v", i, cs, learned[0])
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosGeneralizedGridQuorums(t *testing.T) {
+	grid := Grid{Rows: [][]int{{0, 1}, {2, 3, 4}}}
+	pxa, pxh, fts := makeFiltered("generalized-grid-quorums", 5, WithQuorumSystem(grid))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: Generalized Paxos counts grid quorums ...")
+
+	// peers 0, 2 and 3 are a majority, but hold no complete row.
+	isolate(pxh, fts, []int{0, 2, 3}, []int{1, 4})
+	pxa[0].AppendCommand(0, "set-0")
+	time.Sleep(time.Second)
+	if _, v := pxa[0].Status(0); len(toCStruct(v)) != 0 {
+		t.Fatalf("peer 0 learned %v without a grid quorum, want: nothing", v)
+	}
+
+	// peer 1 completes row {0, 1}.
+	isolate(pxh, fts, []int{0, 1, 2, 3}, []int{4})
+	for iters := 0; iters < 50; iters++ {
+		if _, v := pxa[0].Status(0); toCStruct(v).contains("set-0") {
+			fmt.Println("  ... Passed")
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("set-0 not learned with a grid quorum")
+}
@@ -0,0 +1,406 @@
+package gopaxos
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// EPaxos (Egalitarian Paxos) has no leader and no shared sequence of
+// instances. Every replica owns its own row of instances and commits
+// commands there, together with the attributes that order them:
+//
+//   deps  the interfering commands the replicas knew about
+//   seq   one more than the largest seq among deps
+//
+// The command leader sends PreAccept to the voters. Each one merges its own
+// interfering commands into the attributes. If a fast quorum of N-F
+// replicas, F = (N-1)/2 and the leader included, answers and every answer
+// reports unchanged attributes, the command is committed after one round
+// trip. Otherwise the leader runs an Accept round with the union of the
+// attributes on a majority, N/2+1 replicas with the leader, before
+// committing. Both quorums are majorities, so the quorums of any two
+// interfering commands share a replica, which orders one after the other.
+// With fewer than three voters F is 0 and both paths need every voter.
+//
+// Committed commands execute in an order every replica derives the same
+// way. Strongly connected components of the dependency graph execute in
+// reverse topological order, and commands inside a component by (seq,
+// replica, instance).
+//
+// EPaxos commands run on the config of the lowest undecided Start instance.
+// This implementation does not recover the instances of a failed command
+// leader, so its commands stay uncommitted until it comes back.
+
+// InstanceID names an EPaxos instance: the Instance-th command led by Replica.
+type InstanceID struct {
+	Replica  int
+	Instance int
+}
+
+func (id InstanceID) String() string {
+	return fmt.Sprintf("%d.%d", id.Replica, id.Instance)
+}
+
+func (id InstanceID) less(other InstanceID) bool {
+	if id.Replica != other.Replica {
+		return id.Replica < other.Replica
+	}
+	return id.Instance < other.Instance
+}
+
+// Interferes reports whether two commands must be executed in the same order
+// on every replica, e.g. because they write the same key.
+type Interferes func(a, b Value) bool
+
+type commandStatus int
+
+const (
+	preAccepted commandStatus = iota + 1
+	accepted
+	committed
+)
+
+type command struct {
+	value    Value
+	seq      int
+	deps     []InstanceID // sorted
+	status   commandStatus
+	executed bool
+}
+
+type epaxosState struct {
+	interferes Interferes
+	commands   map[InstanceID]*command
+	next       int          // next instance led by this replica
+	order      []InstanceID // executed commands, in execution order
+}
+
+// WithInterferes enables EPaxos commands, see StartCommand. Commands for
+// which f is false may commit and execute in any order.
+func WithInterferes(f Interferes) Option {
+	return func(p *Paxos) {
+		p.epaxos.interferes = f
+	}
+}
+
+func newEPaxosState() *epaxosState {
+	return &epaxosState{
+		interferes: func(a, b Value) bool { return true },
+		commands:   make(map[InstanceID]*command),
+	}
+}
+
+// attributes returns the seq and deps of v given every command known
+// locally, merged into seq and deps proposed by the command leader.
+func (e *epaxosState) attributes(id InstanceID, v Value, seq int, deps []InstanceID) (int, []InstanceID) {
+	all := make(map[InstanceID]bool)
+	for _, d := range deps {
+		all[d] = true
+	}
+	for other, cmd := range e.commands {
+		if other == id || !e.interferes(v, cmd.value) {
+			continue
+		}
+		all[other] = true
+		if cmd.seq+1 > seq {
+			seq = cmd.seq + 1
+		}
+	}
+	return seq, sortedIDs(all)
+}
+
+// record stores a command at status, never moving it backward.
+func (e *epaxosState) record(id InstanceID, v Value, seq int, deps []InstanceID, status commandStatus) {
+	cmd, ok := e.commands[id]
+	if !ok {
+		cmd = &command{}
+		e.commands[id] = cmd
+	}
+	if cmd.status == committed {
+		return
+	}
+	cmd.value, cmd.seq, cmd.deps = v, seq, deps
+	if status > cmd.status {
+		cmd.status = status
+	}
+}
+
+// execute runs every committed command whose dependencies are all
+// committed, using Tarjan's algorithm to find strongly connected components.
+func (e *epaxosState) execute() {
+	var roots []InstanceID
+	for id, cmd := range e.commands {
+		if cmd.status == committed && !cmd.executed {
+			roots = append(roots, id)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].less(roots[j]) })
+
+	for _, root := range roots {
+		if e.commands[root].executed || !e.executable(root, make(map[InstanceID]bool)) {
+			continue
+		}
+		t := &tarjan{e: e, index: make(map[InstanceID]int), low: make(map[InstanceID]int), onStack: make(map[InstanceID]bool)}
+		t.visit(root)
+	}
+}
+
+// executable reports whether id and everything it transitively depends on
+// is committed.
+func (e *epaxosState) executable(id InstanceID, seen map[InstanceID]bool) bool {
+	if seen[id] {
+		return true
+	}
+	seen[id] = true
+	cmd, ok := e.commands[id]
+	if !ok || cmd.status != committed {
+		return false
+	}
+	if cmd.executed {
+		return true
+	}
+	for _, d := range cmd.deps {
+		if !e.executable(d, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+type tarjan struct {
+	e       *epaxosState
+	counter int
+	index   map[InstanceID]int
+	low     map[InstanceID]int
+	stack   []InstanceID
+	onStack map[InstanceID]bool
+}
+
+func (t *tarjan) visit(id InstanceID) {
+	t.index[id] = t.counter
+	t.low[id] = t.counter
+	t.counter++
+	t.stack = append(t.stack, id)
+	t.onStack[id] = true
+
+	for _, d := range t.e.commands[id].deps {
+		if t.e.commands[d].executed {
+			continue
+		}
+		if _, ok := t.index[d]; !ok {
+			t.visit(d)
+			if t.low[d] < t.low[id] {
+				t.low[id] = t.low[d]
+			}
+		} else if t.onStack[d] && t.index[d] < t.low[id] {
+			t.low[id] = t.index[d]
+		}
+	}
+
+	if t.low[id] != t.index[id] {
+		return
+	}
+	var scc []InstanceID
+	for {
+		top := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[top] = false
+		scc = append(scc, top)
+		if top == id {
+			break
+		}
+	}
+	sort.Slice(scc, func(i, j int) bool {
+		a, b := t.e.commands[scc[i]], t.e.commands[scc[j]]
+		if a.seq != b.seq {
+			return a.seq < b.seq
+		}
+		return scc[i].less(scc[j])
+	})
+	for _, member := range scc {
+		t.e.commands[member].executed = true
+		t.e.order = append(t.e.order, member)
+	}
+}
+
+// StartCommand commits v as a new EPaxos command led by this replica and
+// returns its instance. Unlike Start it needs no seq: commands that do not
+// interfere commit in one round trip no matter how many replicas propose.
+func (p *Paxos) StartCommand(v Value) InstanceID {
+	p.mu.Lock()
+	id := InstanceID{Replica: p.id, Instance: p.epaxos.next}
+	p.epaxos.next++
+	seq, deps := p.epaxos.attributes(id, v, 0, nil)
+	p.epaxos.record(id, v, seq, deps, preAccepted)
+	config := p.currentConfig()
+	p.mu.Unlock()
+
+	go p.leadCommand(p.ctx, config, id, v, seq, deps)
+	return id
+}
+
+// leadCommand commits the command of instance id, giving up when ctx is
+// done.
+func (p *Paxos) leadCommand(ctx context.Context, config Config, id InstanceID, v Value, seq int, deps []InstanceID) {
+	// quorum sizes count the leader, acks only the other voters.
+	voters := len(config.Voters())
+	f := (voters - 1) / 2
+	fastAcks, slowAcks := voters-f-1, voters/2
+	req := &Request{FromID: p.ID(), Instance: id, Seq: seq, Deps: deps, Value: v}
+	replies := p.callOthers(ctx, config, "Handler.OnReceivePreAccept", req, false)
+
+	unchanged := 0
+	for _, resp := range replies {
+		if resp.Seq > req.Seq {
+			req.Seq = resp.Seq
+		}
+		if resp.Seq == seq && equalIDs(resp.Deps, deps) {
+			unchanged++
+		}
+		req.Deps = unionIDs(req.Deps, resp.Deps)
+	}
+
+	if unchanged >= fastAcks && unchanged == len(replies) {
+		p.commitCommand(ctx, config, req)
+		return
+	}
+
+	// slow path: make the merged attributes durable on a majority first.
+	for ctx.Err() == nil {
+		p.mu.Lock()
+		p.epaxos.record(id, v, req.Seq, req.Deps, accepted)
+		p.mu.Unlock()
+		acks := 0
+		for _, resp := range p.callOthers(ctx, config, "Handler.OnReceiveCommandAccept", req, false) {
+			if resp.OK {
+				acks++
+			}
+		}
+		if acks >= slowAcks {
+			p.commitCommand(ctx, config, req)
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(rand.Intn(100)) * time.Millisecond):
+		}
+	}
+}
+
+// commitCommand commits the command in req locally and on every member.
+func (p *Paxos) commitCommand(ctx context.Context, config Config, req *Request) {
+	NewHandler(p).OnReceiveCommandCommit(req, &Response{})
+	p.callOthers(ctx, config, "Handler.OnReceiveCommandCommit", req, true)
+}
+
+// CommandStatus reports whether the command in instance id has committed,
+// and its value.
+func (p *Paxos) CommandStatus(id InstanceID) (bool, Value) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cmd, ok := p.epaxos.commands[id]
+	if !ok || cmd.status != committed {
+		return false, nil
+	}
+	return true, cmd.value
+}
+
+// ExecutionOrder returns the EPaxos commands executed so far, in order.
+// Interfering commands appear in the same relative order on every replica.
+func (p *Paxos) ExecutionOrder() []InstanceID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]InstanceID(nil), p.epaxos.order...)
+}
+
+// callOthers sends req to the other voters of config, or to every other
+// member including learners if members is set, and returns the replies of
+// those that answered.
+func (p *Paxos) callOthers(ctx context.Context, config Config, method string, req *Request, members bool) map[int]Response {
+	ids := config.Voters()
+	if members {
+		ids = config.Members()
+	}
+	return p.callPeers(ctx, config, removeID(ids, p.ID()), method, req)
+}
+
+// OnReceivePreAccept merges this replica's interfering commands into the
+// attributes proposed by a command leader.
+func (h *Handler) OnReceivePreAccept(req *Request, response *Response) error {
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	if !h.pxs.currentConfig().IsVoter(h.pxs.id) {
+		return fmt.Errorf("peer %d is not a voter", h.pxs.id)
+	}
+	e := h.pxs.epaxos
+	seq, deps := e.attributes(req.Instance, req.Value, req.Seq, req.Deps)
+	e.record(req.Instance, req.Value, seq, deps, preAccepted)
+	response.OK = true
+	response.Seq, response.Deps = seq, deps
+	return nil
+}
+
+// OnReceiveCommandAccept stores the final attributes of a command on the
+// slow path.
+func (h *Handler) OnReceiveCommandAccept(req *Request, response *Response) error {
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	if !h.pxs.currentConfig().IsVoter(h.pxs.id) {
+		return fmt.Errorf("peer %d is not a voter", h.pxs.id)
+	}
+	h.pxs.epaxos.record(req.Instance, req.Value, req.Seq, req.Deps, accepted)
+	response.OK = true
+	return nil
+}
+
+// OnReceiveCommandCommit commits a command and executes whatever became
+// executable.
+func (h *Handler) OnReceiveCommandCommit(req *Request, response *Response) error {
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	e := h.pxs.epaxos
+	e.record(req.Instance, req.Value, req.Seq, req.Deps, committed)
+	e.execute()
+	response.OK = true
+	return nil
+}
+
+func sortedIDs(set map[InstanceID]bool) []InstanceID {
+	var ids []InstanceID
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+	return ids
+}
+
+func unionIDs(a, b []InstanceID) []InstanceID {
+	set := make(map[InstanceID]bool)
+	for _, id := range a {
+		set[id] = true
+	}
+	for _, id := range b {
+		set[id] = true
+	}
+	return sortedIDs(set)
+}
+
+func equalIDs(a, b []InstanceID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	gob.Register(InstanceID{})
+}
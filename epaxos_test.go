@@ -0,0 +1,208 @@
+package gopaxos
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEPaxosExecutionOrderIsDeterministic(t *testing.T) {
+	fmt.Println("Test: EPaxos execution order is deterministic ...")
+
+	a := InstanceID{Replica: 0, Instance: 0}
+	b := InstanceID{Replica: 1, Instance: 0}
+	c := InstanceID{Replica: 2, Instance: 0}
+	d := InstanceID{Replica: 0, Instance: 1}
+	commits := []struct {
+		id   InstanceID
+		seq  int
+		deps []InstanceID
+	}{
+		// a and b depend on each other, d follows both, c is independent.
+		{a, 2, []InstanceID{b}},
+		{b, 1, []InstanceID{a}},
+		{c, 1, nil},
+		{d, 3, []InstanceID{a, b}},
+	}
+
+	var orders [][]InstanceID
+	for _, perm := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 3, 0, 1}} {
+		e := newEPaxosState()
+		for _, i := range perm {
+			cm := commits[i]
+			e.record(cm.id, cm.id.String(), cm.seq, cm.deps, committed)
+			e.execute()
+		}
+		orders = append(orders, e.order)
+	}
+
+	pos := func(order []InstanceID, id InstanceID) int {
+		for i, x := range order {
+			if x == id {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, order := range orders {
+		if len(order) != 4 {
+			t.Fatalf("executed %v, want: all 4 commands", order)
+		}
+		// inside the a-b cycle the lower seq goes first.
+		if !(pos(order, b) < pos(order, a) && pos(order, a) < pos(order, d)) {
+			t.Fatalf("order %v, want: b before a before d", order)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestEPaxosWaitsForDependencies(t *testing.T) {
+	fmt.Println("Test: EPaxos waits for uncommitted dependencies ...")
+
+	a := InstanceID{Replica: 0, Instance: 0}
+	b := InstanceID{Replica: 1, Instance: 0}
+	e := newEPaxosState()
+	e.record(b, "b", 0, nil, preAccepted)
+	e.record(a, "a", 1, []InstanceID{b}, committed)
+	e.execute()
+	if len(e.order) != 0 {
+		t.Fatalf("executed %v before its dependency committed", e.order)
+	}
+	e.record(b, "b", 0, nil, committed)
+	e.execute()
+	if want := []InstanceID{b, a}; !reflect.DeepEqual(e.order, want) {
+		t.Fatalf("order = %v, want: %v", e.order, want)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosEPaxosInterferingCommands(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	// commands are "key=value"; writes to the same key interfere.
+	sameKey := func(a, b Value) bool {
+		return strings.SplitN(a.(string), "=", 2)[0] == strings.SplitN(b.(string), "=", 2)[0]
+	}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("epaxos-interfering-commands", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithInterferes(sameKey))
+	}
+
+	fmt.Println("Test: EPaxos, interfering commands ...")
+
+	var ids []InstanceID
+	for round := 0; round < 3; round++ {
+		for i := 0; i < npaxos; i++ {
+			ids = append(ids, pxa[i].StartCommand(fmt.Sprintf("x=%d-%d", i, round)))
+			ids = append(ids, pxa[i].StartCommand(fmt.Sprintf("y%d=%d", i, round)))
+		}
+	}
+
+	for iters := 0; iters < 50; iters++ {
+		done := true
+		for i := 0; i < npaxos; i++ {
+			if len(pxa[i].ExecutionOrder()) != len(ids) {
+				done = false
+			}
+		}
+		if done {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	var want []InstanceID
+	for i := 0; i < npaxos; i++ {
+		order := pxa[i].ExecutionOrder()
+		if len(order) != len(ids) {
+			t.Fatalf("peer %d executed %d commands, want: %d", i, len(order), len(ids))
+		}
+		// only commands on x interfere across replicas.
+		var onX []InstanceID
+		for _, id := range order {
+			if _, v := pxa[i].CommandStatus(id); strings.HasPrefix(v.(string), "x=") {
+				onX = append(onX, id)
+			}
+		}
+		if i == 0 {
+			want = onX
+		} else if !reflect.DeepEqual(onX, want) {
+			t.Fatalf("peer %d executed x in order %v, peer 0 in %v", i, onX, want)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosEPaxosTwoVoters(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("epaxos-two-voters", 2)
+	defer cleanup(pxa)
+
+	fmt.Println("Test: EPaxos, two voters commit only together ...")
+
+	isolate(pxh, fts, []int{0}, []int{1})
+	id := pxa[0].StartCommand("x=1")
+	time.Sleep(time.Second)
+	if ok, _ := pxa[0].CommandStatus(id); ok {
+		t.Fatalf("command %v committed without peer 1", id)
+	}
+
+	isolate(pxh, fts, nil, nil)
+	for iters := 0; iters < 50; iters++ {
+		ok0, _ := pxa[0].CommandStatus(id)
+		ok1, _ := pxa[1].CommandStatus(id)
+		if ok0 && ok1 {
+			fmt.Println("  ... Passed")
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("command %v not committed on both voters", id)
+}
+
+func TestGoPaxosEPaxosFourVoters(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("epaxos-four-voters", 4)
+	defer cleanup(pxa)
+
+	fmt.Println("Test: EPaxos, four voters commit only on three ...")
+
+	// each half is two of four voters, short of a majority.
+	isolate(pxh, fts, []int{0, 1}, []int{2, 3})
+	a := pxa[0].StartCommand("x=1")
+	b := pxa[2].StartCommand("x=2")
+	time.Sleep(time.Second)
+	for _, id := range []InstanceID{a, b} {
+		for i := range pxa {
+			if ok, _ := pxa[i].CommandStatus(id); ok {
+				t.Fatalf("command %v committed on peer %d with two of four voters", id, i)
+			}
+		}
+	}
+
+	isolate(pxh, fts, nil, nil)
+	for iters := 0; iters < 50; iters++ {
+		done := true
+		for _, id := range []InstanceID{a, b} {
+			for i := range pxa {
+				if ok, _ := pxa[i].CommandStatus(id); !ok {
+					done = false
+				}
+			}
+		}
+		if done {
+			fmt.Println("  ... Passed")
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("commands %v and %v not committed on every voter", a, b)
+}
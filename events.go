@@ -0,0 +1,135 @@
+package gopaxos
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Listeners registered with WithEventListener see every state transition
+// of a peer's acceptor and learner, e.g. to keep an audit trail. They run
+// synchronously, in registration order, on the goroutine making the
+// transition and with the peer's lock held: a listener must return
+// quickly and must not call the peer. EventChannel decouples a slow
+// consumer by queueing events on a buffered channel.
+
+// EventKind is the kind of a state transition. A generalized instance,
+// whose learned history grows, emits EventDecided each time it grows.
+type EventKind int
+
+const (
+	EventPromised        EventKind = iota // the acceptor promised Round
+	EventPromiseRejected                  // the acceptor refused to promise Round
+	EventAccepted                         // the acceptor voted for Value in Round
+	EventDecided                          // the peer learned Value was decided
+	EventForgotten                        // the peer forgot the instance, see Done
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPromised:
+		return "promised"
+	case EventPromiseRejected:
+		return "promise-rejected"
+	case EventAccepted:
+		return "accepted"
+	case EventDecided:
+		return "decided"
+	case EventForgotten:
+		return "forgotten"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event is a state transition of instance Seq on peer Peer.
+type Event struct {
+	Kind  EventKind
+	Peer  int
+	Seq   int
+	Round int   // the round promised or voted in, 0 for decisions
+	From  int   // the proposer whose request caused the transition, or Peer
+	Value Value // the value accepted or decided
+	Time  time.Time
+}
+
+// EventListener receives the events of a peer.
+type EventListener interface {
+	OnEvent(e Event)
+}
+
+// EventListenerFunc adapts a function to an EventListener.
+type EventListenerFunc func(e Event)
+
+func (f EventListenerFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// WithEventListener registers l to receive every event of the peer. It can
+// be given more than once.
+func WithEventListener(l EventListener) Option {
+	if l == nil {
+		panic("invalid event listener, want: not nil")
+	}
+	return func(p *Paxos) {
+		p.listeners = append(p.listeners, l)
+	}
+}
+
+// EventChannel is an EventListener that queues events on a buffered
+// channel. When the buffer is full it drops the event rather than stall
+// the protocol, and counts it.
+type EventChannel struct {
+	c       chan Event
+	dropped atomic.Int64
+}
+
+// NewEventChannel returns an EventChannel buffering up to size events.
+func NewEventChannel(size int) *EventChannel {
+	if size < 1 {
+		panic("invalid event channel size, want: size >= 1")
+	}
+	return &EventChannel{c: make(chan Event, size)}
+}
+
+// C returns the channel events are delivered on.
+func (c *EventChannel) C() <-chan Event {
+	return c.c
+}
+
+// Dropped returns the number of events dropped on a full buffer.
+func (c *EventChannel) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+func (c *EventChannel) OnEvent(e Event) {
+	select {
+	case c.c <- e:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// emit sends an event to every listener. p.mu must be held.
+func (p *Paxos) emit(kind EventKind, seq, round, from int, v Value) {
+	if len(p.listeners) == 0 {
+		return
+	}
+	e := Event{Kind: kind, Peer: p.id, Seq: seq, Round: round, From: from, Value: v, Time: p.clock.Now()}
+	for _, l := range p.listeners {
+		l.OnEvent(e)
+	}
+}
+
+// emitVote emits the event of an acceptor's answer to a prepare from req,
+// or of its vote for req.Value in req.Round if it accepted one. p.mu must
+// be held.
+func (p *Paxos) emitVote(prepare bool, req *Request, ok bool) {
+	switch {
+	case prepare && ok:
+		p.emit(EventPromised, req.Seq, req.Round, req.FromID, nil)
+	case prepare:
+		p.emit(EventPromiseRejected, req.Seq, req.Round, req.FromID, nil)
+	case ok:
+		p.emit(EventAccepted, req.Seq, req.Round, req.FromID, req.Value)
+	}
+}
@@ -0,0 +1,96 @@
+package gopaxos
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEventsFollowAcceptorTransitions(t *testing.T) {
+	fmt.Println("Test: Listeners see every acceptor transition ...")
+
+	var events []Event
+	record := EventListenerFunc(func(e Event) { events = append(events, e) })
+	ch := NewEventChannel(2)
+	peers := []string{"a:1/x", "b:1/x", "c:1/x"}
+	p := newPaxos(peers, 1, WithEventListener(record), WithEventListener(ch))
+	h := NewHandler(p)
+
+	h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 3, Round: 2}, &Response{})
+	h.OnReceiveFastPrepare(&Request{FromID: 2, Seq: 3, Round: 1}, &Response{})
+	h.OnReceiveFastAccept(&Request{FromID: 0, Seq: 3, Round: 2, Value: "x"}, &Response{})
+	h.OnReceiveFastAccept(&Request{FromID: 2, Seq: 3, Round: 1, Value: "y"}, &Response{})
+	h.OnReceiveDecision(&Request{FromID: 0, Seq: 3, Value: "x"}, &Response{})
+	h.OnReceiveDecision(&Request{FromID: 0, Seq: 3, Value: "x"}, &Response{})
+
+	want := []Event{
+		{Kind: EventPromised, Peer: 1, Seq: 3, Round: 2, From: 0},
+		{Kind: EventPromiseRejected, Peer: 1, Seq: 3, Round: 1, From: 2},
+		{Kind: EventAccepted, Peer: 1, Seq: 3, Round: 2, From: 0, Value: "x"},
+		{Kind: EventDecided, Peer: 1, Seq: 3, From: 1, Value: "x"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %v, want: %d", len(events), events, len(want))
+	}
+	for i, e := range events {
+		if e.Time.IsZero() {
+			t.Fatalf("event %d has no time", i)
+		}
+		e.Time = want[i].Time
+		if e != want[i] {
+			t.Fatalf("event %d = %+v, want: %+v", i, e, want[i])
+		}
+	}
+
+	if got := (<-ch.C()).Kind; got != EventPromised {
+		t.Fatalf("first queued event %v, want: %v", got, EventPromised)
+	}
+	if got := (<-ch.C()).Kind; got != EventPromiseRejected {
+		t.Fatalf("second queued event %v, want: %v", got, EventPromiseRejected)
+	}
+	if ch.Dropped() != 2 {
+		t.Fatalf("dropped %d events, want: 2", ch.Dropped())
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestEventsReportForgottenInstances(t *testing.T) {
+	fmt.Println("Test: Listeners see forgotten instances ...")
+
+	var forgotten []int
+	record := EventListenerFunc(func(e Event) {
+		if e.Kind == EventForgotten {
+			forgotten = append(forgotten, e.Seq)
+		}
+	})
+	peers := []string{"a:1/x", "b:1/x", "c:1/x"}
+	p := newPaxos(peers, 1, WithEventListener(record))
+	h := NewHandler(p)
+
+	h.OnReceiveFastAccept(&Request{FromID: 0, Seq: 2, Round: 1, Value: "x"}, &Response{})
+	h.OnReceiveDecision(&Request{FromID: 0, Seq: 3, Value: "y"}, &Response{})
+	p.Done(3)
+	for _, from := range []int{0, 2} {
+		if err := h.Admit("Handler.OnReceiveDecision", &Request{FromID: from, ClusterID: p.ClusterID(), Forget: 4}); err != nil {
+			t.Fatal(err)
+		}
+		if from == 0 && len(forgotten) != 0 {
+			t.Fatalf("forgot %v before every peer was done", forgotten)
+		}
+	}
+
+	if len(forgotten) != 2 || forgotten[0] != 2 || forgotten[1] != 3 {
+		t.Fatalf("forgotten instances %v, want: [2 3]", forgotten)
+	}
+	if m := p.Min(); m != 4 {
+		t.Fatalf("Min() = %d, want: 4", m)
+	}
+	if decided, _ := p.Status(3); decided {
+		t.Fatalf("a forgotten instance is still decided")
+	}
+	if err := h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 3, Round: 5}, &Response{}); err == nil {
+		t.Fatalf("an acceptor promised in a forgotten instance")
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,258 @@
+package gopaxos
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Fast Paxos lets a client skip the leader: round 0 of an instance is a fast
+// round in which every acceptor votes for the first value it receives. A
+// value voted by a fast quorum is chosen after a single round trip. When
+// concurrent clients collide, the one that notices recovers with classic
+// rounds 1, 2, ... in which the value to propose is picked as follows:
+//
+//   k = highest vrnd reported by a classic quorum Q of promises
+//   if k is a classic round, propose its value
+//   if k is the fast round, propose any value w voted by at least
+//     |Q| + fastQuorum - N acceptors of Q, since only such a w can have
+//     been chosen; otherwise propose our own value
+//
+// Safety needs every two fast quorums and every prepare quorum to
+// intersect, 2*fastQuorum + |Q| > 2*N, which FastQuorumSize guarantees for
+// the smallest prepare quorum of the config's QuorumSystem. Classic rounds
+// count promises and accepts with that QuorumSystem too.
+
+// fastRound is the round in which acceptors vote for any value.
+const fastRound = 0
+
+// fastInstance is the acceptor state of one instance run in numbered rounds,
+// by Start, StartFast or in Mencius mode.
+type fastInstance struct {
+	rnd  int   // highest round promised
+	vrnd int   // round of the last vote, -1 if none
+	vval Value // value of the last vote
+}
+
+// FastQuorumSize returns the number of fast round votes that choose a value,
+// derived from the smallest prepare quorum of c's QuorumSystem.
+func (c Config) FastQuorumSize() int {
+	n := len(c.Voters())
+	return (2*n-minQuorumSize(c.QuorumSystem(), PhasePrepare, c.Voters()))/2 + 1
+}
+
+// StartFast starts an agreement on instance seq in a fast round: v is sent
+// to every acceptor directly instead of through a prepare phase. Collisions
+// with other StartFast calls on the same seq are recovered in classic rounds.
+// StartFast and Start may be mixed on the same instance: they share its
+// acceptor state, and the classic rounds of Start keep a value a fast round
+// may have chosen. Values must be comparable with == or be a Batch.
+func (p *Paxos) StartFast(seq int, v Value) {
+	p.mu.Lock()
+	config, ok := p.configFor(seq)
+	if !ok {
+		p.parked[seq] = func() { p.StartFast(seq, v) }
+		p.mu.Unlock()
+		return
+	}
+	if seq > p.maxSeq {
+		p.maxSeq = seq
+	}
+	p.mu.Unlock()
+
+	p.propose(seq, func(ctx context.Context) { p.proposeFast(ctx, seq, v, config) })
+}
+
+func (p *Paxos) proposeFast(ctx context.Context, seq int, v Value, config Config) {
+	voters := config.Voters()
+	rctx, span := p.startRound(ctx, seq, fastRound)
+	replies := p.callVoters(rctx, config, "Handler.OnReceiveFastAccept",
+		&Request{FromID: p.ID(), Seq: seq, Round: fastRound, Value: v})
+	span.End()
+	votes := make(map[Value]int)
+	values := make(map[Value]Value)
+	for _, resp := range replies {
+		if resp.Round == fastRound {
+			votes[voteKey(resp.Value)]++
+			values[voteKey(resp.Value)] = resp.Value
+		}
+	}
+	for key, n := range votes {
+		if n >= config.FastQuorumSize() {
+			p.metrics.rounds.observe(1)
+			p.broadcastDecision(ctx, config, seq, values[key])
+			return
+		}
+	}
+
+	// collision, or too few acceptors answered: recover in classic rounds.
+	pick := func(promises []Response) Value {
+		return pickFastValue(promises, len(voters), config.FastQuorumSize(), v)
+	}
+	p.runClassicRounds(ctx, config, seq, 1, pick, config.IsQuorum)
+}
+
+// runClassicRounds runs classic rounds 1, 2, ... of instance seq on the
+// round-based acceptor state until it is decided or forgotten, or ctx is
+// done. pick chooses the value to
+// propose from the promises of a prepare quorum. prior is the number of
+// rounds the caller ran before, for the rounds metric.
+func (p *Paxos) runClassicRounds(ctx context.Context, config Config, seq, prior int, pick func([]Response) Value, isQuorum func(Phase, []int) bool) {
+	for k := 0; ctx.Err() == nil; k++ {
+		if decided, _ := p.Status(seq); decided || p.forgotten(seq) {
+			return
+		}
+		round := p.nextRound(config, seq)
+		p.logDebug("classic round", "seq", seq, "round", round)
+		rctx, span := p.startRound(ctx, seq, round)
+		replies := p.callVoters(rctx, config, "Handler.OnReceiveFastPrepare",
+			&Request{FromID: p.ID(), Seq: seq, Round: round})
+		var promised []int
+		var promises []Response
+		for id, resp := range replies {
+			if resp.OK {
+				promised = append(promised, id)
+				promises = append(promises, resp)
+			}
+		}
+		if isQuorum(PhasePrepare, promised) {
+			w := pick(promises)
+			replies = p.callVoters(rctx, config, "Handler.OnReceiveFastAccept",
+				&Request{FromID: p.ID(), Seq: seq, Round: round, Value: w})
+			if isQuorum(PhaseAccept, acked(replies)) {
+				span.End()
+				p.metrics.rounds.observe(float64(prior + k + 1))
+				p.broadcastDecision(ctx, config, seq, w)
+				return
+			}
+		}
+		span.End()
+		p.backoff(ctx, seq, k)
+	}
+}
+
+// nextRound returns the classic round this peer runs next in instance seq:
+// the lowest of its rounds, k*len(config.Peers)+ID()+1, above every round
+// it ran in seq before. Two proposals of this peer in one instance, e.g.
+// two AppendCommand calls, therefore never share a ballot.
+func (p *Paxos) nextRound(config Config, seq int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n, round := len(config.Peers), p.id+1
+	if last := p.rounds[seq]; last >= round {
+		round += ((last-round)/n + 1) * n
+	}
+	p.rounds[seq] = round
+	return round
+}
+
+// backoff sleeps for a random time that grows with k, the number of the
+// round of seq that just failed, before the next one. It returns early
+// when ctx is done.
+func (p *Paxos) backoff(ctx context.Context, seq, k int) {
+	_, span := p.startSpan(ctx, "gopaxos.Backoff", seq)
+	defer span.End()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Duration(rand.Intn(10*(k+1))) * time.Millisecond):
+	}
+}
+
+// pickFastValue applies the Fast Paxos value selection rule to the promises
+// of a classic quorum, falling back to v if no value can have been chosen.
+func pickFastValue(promises []Response, n, fastQuorum int, v Value) Value {
+	k := -1
+	for _, resp := range promises {
+		if resp.Round > k {
+			k = resp.Round
+		}
+	}
+	if k < 0 {
+		return v
+	}
+	votes := make(map[Value]int)
+	values := make(map[Value]Value)
+	var w Value
+	for _, resp := range promises {
+		if resp.Round == k {
+			votes[voteKey(resp.Value)]++
+			values[voteKey(resp.Value)] = resp.Value
+			w = resp.Value
+		}
+	}
+	if k != fastRound {
+		return w
+	}
+	for key, count := range votes {
+		if count >= len(promises)+fastQuorum-n {
+			return values[key]
+		}
+	}
+	return v
+}
+
+// fastInstance returns the acceptor state of seq. p.mu must be held.
+func (p *Paxos) fastInstance(seq int) *fastInstance {
+	inst, ok := p.fast[seq]
+	if !ok {
+		inst = &fastInstance{vrnd: -1}
+		p.fast[seq] = inst
+	}
+	return inst
+}
+
+// OnReceiveFastPrepare is phase 1 of a classic recovery round. The reply
+// carries the acceptor's last vote in Round and Value, Round is -1 if none.
+func (h *Handler) OnReceiveFastPrepare(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	inst := h.pxs.fastInstance(req.Seq)
+	if req.Round > inst.rnd {
+		inst.rnd = req.Round
+		if err := h.pxs.saveFast(req.Seq); err != nil {
+			return err
+		}
+		response.OK = true
+	}
+	h.pxs.metrics.voted(true, response.OK)
+	h.pxs.emitVote(true, req, response.OK)
+	response.Round, response.Value = inst.vrnd, inst.vval
+	return nil
+}
+
+// OnReceiveFastAccept votes for req.Value. In the fast round an acceptor
+// votes for the first value it sees; in a classic round it votes unless it
+// promised a higher round.
+func (h *Handler) OnReceiveFastAccept(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	inst := h.pxs.fastInstance(req.Seq)
+	if req.Round == fastRound && h.pxs.mencius != nil && req.FromID != h.pxs.id {
+		go h.pxs.menciusHeard(req.Seq)
+	}
+	if req.Round == fastRound {
+		if inst.rnd == fastRound && inst.vrnd < 0 {
+			inst.vrnd, inst.vval = fastRound, req.Value
+			if err := h.pxs.saveFast(req.Seq); err != nil {
+				return err
+			}
+		}
+		response.OK = inst.vrnd == fastRound && voteKey(inst.vval) == voteKey(req.Value)
+	} else if req.Round >= inst.rnd {
+		inst.rnd, inst.vrnd, inst.vval = req.Round, req.Round, req.Value
+		if err := h.pxs.saveFast(req.Seq); err != nil {
+			return err
+		}
+		response.OK = true
+	}
+	h.pxs.metrics.voted(false, response.OK)
+	h.pxs.emitVote(false, req, response.OK)
+	response.Round, response.Value = inst.vrnd, inst.vval
+	return nil
+}
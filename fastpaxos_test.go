@@ -0,0 +1,278 @@
+package gopaxos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFastPaxosQuorumSize(t *testing.T) {
+	fmt.Println("Test: Fast quorum sizes ...")
+
+	for n, want := range map[int]int{1: 1, 3: 3, 4: 3, 5: 4, 7: 6} {
+		c := Config{Peers: make([]string, n)}
+		for i := range c.Peers {
+			c.Peers[i] = fmt.Sprintf("h:%d/p", i)
+		}
+		qf, qc := c.FastQuorumSize(), c.QuorumSize()
+		if qf != want {
+			t.Fatalf("FastQuorumSize() with %d voters = %d, want: %d", n, qf, want)
+		}
+		if 2*qf+qc <= 2*n {
+			t.Fatalf("%d voters: fast quorums %d and classic quorum %d do not intersect", n, qf, qc)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestFastPaxosQuorumFollowsQuorumSystem(t *testing.T) {
+	fmt.Println("Test: Fast quorums follow the quorum system ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p", "d:1/p", "e:1/p"}
+	for _, tt := range []struct {
+		qs   QuorumSystem
+		want int
+	}{
+		{Flexible{Prepare: 4, Accept: 2}, 4},
+		{Flexible{Prepare: 2, Accept: 4}, 5},
+		{Weighted{Weights: map[int]int{0: 3}}, 5},
+		{Grid{Rows: [][]int{{0, 1}, {2, 3, 4}}}, 5},
+	} {
+		c := Config{Peers: peers, Quorums: tt.qs}
+		if got := c.FastQuorumSize(); got != tt.want {
+			t.Fatalf("FastQuorumSize() with %+v = %d, want: %d", tt.qs, got, tt.want)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestFastPaxosPickValue(t *testing.T) {
+	fmt.Println("Test: Fast Paxos value selection ...")
+
+	vote := func(round int, v Value) Response { return Response{OK: true, Round: round, Value: v} }
+	none := Response{OK: true, Round: -1}
+
+	tests := []struct {
+		name     string
+		promises []Response
+		want     Value
+	}{
+		{"no votes", []Response{none, none, none}, "own"},
+		{"classic round wins", []Response{vote(0, "a"), vote(2, "b"), none}, "b"},
+		// 5 voters, fast quorum 4: "a" may have been chosen with the two
+		// acceptors we did not hear from.
+		{"possibly chosen", []Response{vote(0, "a"), vote(0, "a"), vote(0, "b")}, "a"},
+		{"collision", []Response{vote(0, "a"), vote(0, "b"), vote(0, "c")}, "own"},
+	}
+	for _, tt := range tests {
+		if got := pickFastValue(tt.promises, 5, 4, "own"); got != tt.want {
+			t.Fatalf("%s: pickFastValue() = %v, want: %v", tt.name, got, tt.want)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestFastPaxosAcceptorVotesOnce(t *testing.T) {
+	fmt.Println("Test: Fast acceptor votes once per round ...")
+
+	pxs := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 0)
+	h := NewHandler(pxs)
+
+	var resp Response
+	h.OnReceiveFastAccept(&Request{Seq: 0, Round: fastRound, Value: "x"}, &resp)
+	if !resp.OK {
+		t.Fatalf("first fast vote rejected")
+	}
+	resp = Response{}
+	h.OnReceiveFastAccept(&Request{Seq: 0, Round: fastRound, Value: "y"}, &resp)
+	if resp.OK || resp.Value != "x" {
+		t.Fatalf("second fast vote = %+v, want: rejected, reporting x", resp)
+	}
+
+	resp = Response{}
+	h.OnReceiveFastPrepare(&Request{Seq: 0, Round: 2}, &resp)
+	if !resp.OK || resp.Round != fastRound || resp.Value != "x" {
+		t.Fatalf("prepare(2) = %+v, want: promise reporting x", resp)
+	}
+	resp = Response{}
+	h.OnReceiveFastAccept(&Request{Seq: 0, Round: 1, Value: "y"}, &resp)
+	if resp.OK {
+		t.Fatalf("accept(1) succeeded after promising round 2")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosFastSingleProposer(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("fast-single-proposer", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: Fast Paxos, single proposer ...")
+
+	pxa[0].StartFast(0, "hello")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosFastManyProposersDifferentValues(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("fast-many-proposers-different-values", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: Fast Paxos, many proposers, different values ...")
+
+	for seq := 0; seq < 5; seq++ {
+		pxa[0].StartFast(seq, 100)
+		pxa[1].StartFast(seq, 101)
+		pxa[2].StartFast(seq, 102)
+	}
+	for seq := 0; seq < 5; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosFastMixedWithStart(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("fast-mixed-with-start", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: Fast Paxos, StartFast and Start on the same instance ...")
+
+	for seq := 0; seq < 5; seq++ {
+		pxa[0].StartFast(seq, 100)
+		pxa[1].Start(seq, 101)
+		pxa[2].StartFast(seq, 102)
+	}
+	for seq := 0; seq < 5; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a value chosen in the fast round whose decision was lost.
+	for i := 0; i < npaxos; i++ {
+		NewHandler(pxa[i]).OnReceiveFastAccept(&Request{FromID: 0, Seq: 5, Round: fastRound, Value: "fast"}, &Response{})
+	}
+	pxa[1].Start(5, "classic")
+	if err := waitN(pxa, 5, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if _, v := pxa[1].Status(5); v != "fast" {
+		t.Fatalf("Status(5) = %v, want: fast", v)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosFastGridQuorums(t *testing.T) {
+	grid := Grid{Rows: [][]int{{0, 1}, {2, 3, 4}}}
+	pxa, pxh, fts := makeFiltered("fast-grid-quorums", 5, WithQuorumSystem(grid))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: Fast Paxos counts grid quorums ...")
+
+	// peers 0, 2 and 3 are a majority, but hold no complete row.
+	isolate(pxh, fts, []int{0, 2, 3}, []int{1, 4})
+	pxa[0].StartFast(0, 100)
+	pxa[2].StartFast(0, 102)
+	time.Sleep(time.Second)
+	if n, err := ndecided(pxa, 0); err != nil || n != 0 {
+		t.Fatalf("%d peers decided (%v) without a grid quorum, want: none", n, err)
+	}
+
+	// peer 1 completes row {0, 1}.
+	isolate(pxh, fts, []int{0, 1, 2, 3}, []int{4})
+	if err := waitN(pxa[:4], 0, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosRoundsGrowPerInstance(t *testing.T) {
+	fmt.Println("Test: A peer never reuses a round in an instance ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p"}
+	p := newPaxos(peers, 1)
+	config := p.configs.latest()
+	last := 0
+	for i := 0; i < 4; i++ {
+		round := p.nextRound(config, 7)
+		if round <= last || round%len(peers) != 2 {
+			t.Fatalf("round %d after %d, want: higher and owned by peer 1", round, last)
+		}
+		last = round
+	}
+	if round := p.nextRound(config, 8); round != 2 {
+		t.Fatalf("first round of another instance %d, want: 2", round)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosSamePeerProposalsOnGrid(t *testing.T) {
+	npaxos := 5
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("same-peer-proposals-on-grid", i)
+	}
+	grid := Grid{Rows: [][]int{{0, 1}, {2, 3, 4}}}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithQuorumSystem(grid))
+	}
+
+	fmt.Println("Test: Proposals of one peer in one instance agree on a grid ...")
+
+	// the rows are disjoint prepare quorums, so two proposals of peer 0
+	// sharing a ballot could both complete.
+	for seq := 0; seq < 10; seq++ {
+		for v := 0; v < 4; v++ {
+			pxa[0].Start(seq, 100*seq+v)
+		}
+	}
+	for seq := 0; seq < 10; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,112 @@
+package gopaxos
+
+import "sort"
+
+// An application calls Done(seq) once it no longer needs instances up to
+// seq. Every request and response carries the sender's Forget, one more
+// than the highest seq it called Done with, so each peer learns how far
+// the others are done. Min() is the lowest Forget among the members of the
+// latest config, peers not heard from counting as 0, and a peer forgets
+// the decided value and acceptor state of every instance below it. A
+// forgotten instance is never proposed in or voted in again: every member
+// is done with it, so nobody can ask for it.
+
+// recordForget notes that peer id may forget every instance below forget,
+// and forgets what every member is done with. p.mu must be held.
+func (p *Paxos) recordForget(id, forget int) {
+	if forget <= p.forgetBelow[id] {
+		return
+	}
+	p.forgetBelow[id] = forget
+	members := p.configs.latest().Members()
+	if len(members) == 0 {
+		return
+	}
+	min := p.forgetBelow[members[0]]
+	for _, m := range members[1:] {
+		if p.forgetBelow[m] < min {
+			min = p.forgetBelow[m]
+		}
+	}
+	if min > p.minSeq {
+		p.forget(min)
+	}
+}
+
+// forget drops every instance below min. p.mu must be held.
+func (p *Paxos) forget(min int) {
+	held := make(map[int]bool)
+	cl := p.logger
+	for seq := range cl.data {
+		if seq < min {
+			held[seq] = true
+			cl.bytes -= cl.sizes[seq]
+			delete(cl.data, seq)
+			delete(cl.sizes, seq)
+		}
+	}
+	for seq := range p.fast {
+		if seq < min {
+			held[seq] = true
+			delete(p.fast, seq)
+		}
+	}
+	for seq := range p.rounds {
+		if seq < min {
+			delete(p.rounds, seq)
+		}
+	}
+	for seq := range p.general {
+		if seq < min {
+			held[seq] = true
+			delete(p.general, seq)
+		}
+	}
+	for seq := range p.parked {
+		if seq < min {
+			delete(p.parked, seq)
+		}
+	}
+	if p.mencius != nil {
+		for seq := range p.mencius.proposed {
+			if seq < min {
+				delete(p.mencius.proposed, seq)
+			}
+		}
+	}
+	if cl.next < min {
+		cl.next = min
+		for {
+			if _, ok := cl.data[cl.next]; !ok {
+				break
+			}
+			cl.next++
+		}
+	}
+	p.minSeq = min
+	if min-1 > p.maxSeq {
+		p.maxSeq = min - 1
+	}
+	if p.storage != nil {
+		if err := p.storage.Forget(min); err != nil {
+			p.logWarn("forget acceptor state", "below", min, "err", err)
+		}
+	}
+
+	seqs := make([]int, 0, len(held))
+	for seq := range held {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		p.emit(EventForgotten, seq, 0, p.id, nil)
+	}
+	p.logDebug("forgot instances", "below", min, "count", len(seqs))
+}
+
+// forgotten reports whether instance seq was forgotten.
+func (p *Paxos) forgotten(seq int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return seq < p.minSeq
+}
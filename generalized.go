@@ -0,0 +1,323 @@
+package gopaxos
+
+import "context"
+
+// Generalized Paxos agrees on a growing CStruct per instance instead of a
+// single value. In fast round 0 each acceptor appends every command it
+// receives to its own vote, so commuting commands never conflict no matter
+// in which order acceptors see them. A history is chosen once a fast quorum
+// voted for histories it is a prefix of; the learned value of an instance is
+// the least upper bound of everything chosen.
+//
+// When two non-commuting commands reach acceptors in different orders,
+// neither gets chosen in round 0 and the proposer recovers with a classic
+// round. Its value extends the history proved safe by a prepare quorum Q
+// of promises, which for fast round k is the least upper bound, over every
+// fast quorum R, of the common prefix of the round k votes in Q and R.
+// After recovery, later commands of the instance also go through classic
+// rounds. Classic rounds count quorums with the config's QuorumSystem.
+
+// generalInstance is the acceptor state of one Generalized Paxos instance.
+type generalInstance struct {
+	rnd  int     // highest round promised
+	vrnd int     // round of the last vote, -1 if none
+	vval CStruct // last vote
+}
+
+// WithCommute sets the commutativity relation of AppendCommand. Commands for
+// which f is true may be learned in either order. Without it, no two
+// commands commute.
+func WithCommute(f Commute) Option {
+	return func(p *Paxos) {
+		p.commute = f
+	}
+}
+
+// AppendCommand starts an agreement to add cmd to the history of instance
+// seq. Status(seq) returns the CStruct learned so far, which only grows.
+func (p *Paxos) AppendCommand(seq int, cmd Value) {
+	p.mu.Lock()
+	config, ok := p.configFor(seq)
+	if !ok {
+		p.parked[seq] = func() { p.AppendCommand(seq, cmd) }
+		p.mu.Unlock()
+		return
+	}
+	if seq > p.maxSeq {
+		p.maxSeq = seq
+	}
+	p.mu.Unlock()
+
+	p.propose(seq, func(ctx context.Context) { p.proposeCommand(ctx, config, seq, cmd) })
+}
+
+func (p *Paxos) proposeCommand(ctx context.Context, config Config, seq int, cmd Value) {
+	rctx, span := p.startRound(ctx, seq, fastRound)
+	replies := p.callVoters(rctx, config, "Handler.OnReceiveCStructAppend",
+		&Request{FromID: p.ID(), Seq: seq, Round: fastRound, Value: cmd})
+	span.End()
+	votes := make(map[int]CStruct)
+	for id, resp := range replies {
+		if resp.OK {
+			votes[id] = toCStruct(resp.Value)
+		}
+	}
+	if learned := p.chosen(config, votes); learned.contains(cmd) {
+		p.metrics.rounds.observe(1)
+		p.broadcastLearned(ctx, config, seq, learned)
+		return
+	}
+	p.recoverCommand(ctx, config, seq, 1, cmd)
+}
+
+// recoverCommand runs classic rounds of instance seq until cmd is learned,
+// or until any history is learned if cmd is nil, or seq is forgotten, or
+// ctx is done. prior is
+// the number of rounds the caller ran before, for the rounds metric.
+func (p *Paxos) recoverCommand(ctx context.Context, config Config, seq, prior int, cmd Value) {
+	for k := 0; ctx.Err() == nil; k++ {
+		if learned, v := p.Status(seq); learned && (cmd == nil || toCStruct(v).contains(cmd)) || p.forgotten(seq) {
+			return
+		}
+		round := p.nextRound(config, seq)
+		rctx, span := p.startRound(ctx, seq, round)
+		replies := p.callVoters(rctx, config, "Handler.OnReceiveCStructPrepare",
+			&Request{FromID: p.ID(), Seq: seq, Round: round})
+		promises := make(map[int]Response)
+		var promised []int
+		for id, resp := range replies {
+			if resp.OK {
+				promises[id] = resp
+				promised = append(promised, id)
+			}
+		}
+		if config.IsQuorum(PhasePrepare, promised) {
+			w := p.provedSafe(config, promises)
+			for _, resp := range promises {
+				for _, c := range toCStruct(resp.Value) {
+					w = w.Append(c)
+				}
+			}
+			if cmd != nil {
+				w = w.Append(cmd)
+			}
+			replies = p.callVoters(rctx, config, "Handler.OnReceiveCStructAccept",
+				&Request{FromID: p.ID(), Seq: seq, Round: round, Value: w})
+			if config.IsQuorum(PhaseAccept, acked(replies)) {
+				span.End()
+				p.metrics.rounds.observe(float64(prior + k + 1))
+				p.broadcastLearned(ctx, config, seq, w)
+				return
+			}
+		}
+		span.End()
+		p.backoff(ctx, seq, k)
+	}
+}
+
+// chosen returns the history chosen by the fast round votes, the least upper
+// bound over every fast quorum among the voters of the common prefix of
+// their votes.
+func (p *Paxos) chosen(config Config, votes map[int]CStruct) CStruct {
+	var ids []int
+	for id := range votes {
+		ids = append(ids, id)
+	}
+	var learned CStruct
+	for _, quorum := range combinations(ids, config.FastQuorumSize()) {
+		var css []CStruct
+		for _, id := range quorum {
+			css = append(css, votes[id])
+		}
+		if next, ok := lub(learned, glbAll(css, p.commute), p.commute); ok {
+			learned = next
+		}
+	}
+	return learned
+}
+
+// provedSafe returns the history that may have been chosen in a round
+// below the one the promises were given for.
+func (p *Paxos) provedSafe(config Config, promises map[int]Response) CStruct {
+	k := -1
+	for _, resp := range promises {
+		if resp.Round > k {
+			k = resp.Round
+		}
+	}
+	if k < 0 {
+		return nil
+	}
+	if k != fastRound {
+		for _, resp := range promises {
+			if resp.Round == k {
+				return toCStruct(resp.Value)
+			}
+		}
+	}
+	var safe CStruct
+	for _, quorum := range combinations(config.Voters(), config.FastQuorumSize()) {
+		var css []CStruct
+		voted := true
+		for _, id := range quorum {
+			resp, ok := promises[id]
+			if !ok {
+				continue
+			}
+			if resp.Round != k {
+				// an acceptor of this quorum skipped round k, so it chose nothing.
+				voted = false
+				break
+			}
+			css = append(css, toCStruct(resp.Value))
+		}
+		if !voted || len(css) == 0 {
+			continue
+		}
+		if next, ok := lub(safe, glbAll(css, p.commute), p.commute); ok {
+			safe = next
+		}
+	}
+	return safe
+}
+
+// broadcastLearned records cs as learned in seq and tells every member.
+func (p *Paxos) broadcastLearned(ctx context.Context, config Config, seq int, cs CStruct) {
+	p.learn(seq, cs)
+	others := removeID(config.Members(), p.ID())
+	p.callPeers(ctx, config, others, "Handler.OnReceiveCStructLearned", &Request{FromID: p.ID(), Seq: seq, Value: cs})
+}
+
+// learn extends the learned history of seq with cs.
+func (p *Paxos) learn(seq int, cs CStruct) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seq < p.minSeq {
+		return
+	}
+	v, _ := p.logger.Get(seq)
+	old := toCStruct(v)
+	learned, ok := lub(old, cs, p.commute)
+	if !ok {
+		return
+	}
+	// only the new commands are encoded, re-encoding the whole history each
+	// time it grows would be quadratic.
+	size := p.logger.sizes[seq]
+	for _, c := range learned {
+		if !old.contains(c) {
+			size += encodedSize(c)
+		}
+	}
+	p.logger.Write(seq, learned, size)
+	if v == nil {
+		p.metrics.decisions.Add(1)
+	}
+	p.metrics.decided(seq)
+	p.logDebug("learned", "seq", seq, "value", learned)
+	if len(learned) > len(old) {
+		p.emit(EventDecided, seq, 0, p.id, learned)
+	}
+	if seq > p.maxSeq {
+		p.maxSeq = seq
+	}
+}
+
+// generalInstance returns the acceptor state of seq. p.mu must be held.
+func (p *Paxos) generalInstance(seq int) *generalInstance {
+	inst, ok := p.general[seq]
+	if !ok {
+		inst = &generalInstance{vrnd: -1}
+		p.general[seq] = inst
+	}
+	return inst
+}
+
+// OnReceiveCStructAppend appends req.Value to this acceptor's round 0 vote,
+// unless it promised a classic round. The reply carries the current vote.
+func (h *Handler) OnReceiveCStructAppend(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	inst := h.pxs.generalInstance(req.Seq)
+	if inst.rnd == fastRound {
+		inst.vrnd, inst.vval = fastRound, inst.vval.Append(req.Value)
+		if err := h.pxs.saveGeneral(req.Seq); err != nil {
+			return err
+		}
+		response.OK = true
+	}
+	h.pxs.metrics.voted(false, response.OK)
+	h.pxs.emitVote(false, req, response.OK)
+	response.Round, response.Value = inst.vrnd, inst.vval
+	return nil
+}
+
+// OnReceiveCStructPrepare is phase 1 of a classic round.
+func (h *Handler) OnReceiveCStructPrepare(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	inst := h.pxs.generalInstance(req.Seq)
+	if req.Round > inst.rnd {
+		inst.rnd = req.Round
+		if err := h.pxs.saveGeneral(req.Seq); err != nil {
+			return err
+		}
+		response.OK = true
+	}
+	h.pxs.metrics.voted(true, response.OK)
+	h.pxs.emitVote(true, req, response.OK)
+	response.Round, response.Value = inst.vrnd, inst.vval
+	return nil
+}
+
+// OnReceiveCStructAccept is phase 2 of a classic round.
+func (h *Handler) OnReceiveCStructAccept(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	inst := h.pxs.generalInstance(req.Seq)
+	if req.Round >= inst.rnd {
+		inst.rnd, inst.vrnd, inst.vval = req.Round, req.Round, toCStruct(req.Value)
+		if err := h.pxs.saveGeneral(req.Seq); err != nil {
+			return err
+		}
+		response.OK = true
+	}
+	h.pxs.metrics.voted(false, response.OK)
+	h.pxs.emitVote(false, req, response.OK)
+	return nil
+}
+
+// OnReceiveCStructLearned extends the learned history of req.Seq.
+func (h *Handler) OnReceiveCStructLearned(req *Request, response *Response) error {
+	h.pxs.learn(req.Seq, toCStruct(req.Value))
+	return nil
+}
+
+func toCStruct(v Value) CStruct {
+	cs, _ := v.(CStruct)
+	return cs
+}
+
+// combinations returns every subset of ids with k elements.
+func combinations(ids []int, k int) [][]int {
+	if k == 0 {
+		return [][]int{nil}
+	}
+	if len(ids) < k {
+		return nil
+	}
+	var out [][]int
+	for _, rest := range combinations(ids[1:], k-1) {
+		out = append(out, append([]int{ids[0]}, rest...))
+	}
+	return append(out, combinations(ids[1:], k)...)
+}
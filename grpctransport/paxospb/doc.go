@@ -0,0 +1,5 @@
+// Package paxospb holds the protobuf messages and gRPC service of the
+// gopaxos gRPC transport.
+package paxospb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative paxos.proto
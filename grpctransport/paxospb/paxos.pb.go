@@ -0,0 +1,603 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: paxos.proto
+
+package paxospb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type InstanceID struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Replica       int64                  `protobuf:"varint,1,opt,name=replica,proto3" json:"replica,omitempty"`
+	Instance      int64                  `protobuf:"varint,2,opt,name=instance,proto3" json:"instance,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InstanceID) Reset() {
+	*x = InstanceID{}
+	mi := &file_paxos_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InstanceID) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InstanceID) ProtoMessage() {}
+
+func (x *InstanceID) ProtoReflect() protoreflect.Message {
+	mi := &file_paxos_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InstanceID.ProtoReflect.Descriptor instead.
+func (*InstanceID) Descriptor() ([]byte, []int) {
+	return file_paxos_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *InstanceID) GetReplica() int64 {
+	if x != nil {
+		return x.Replica
+	}
+	return 0
+}
+
+func (x *InstanceID) GetInstance() int64 {
+	if x != nil {
+		return x.Instance
+	}
+	return 0
+}
+
+type Request struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FromId        int64                  `protobuf:"varint,1,opt,name=from_id,json=fromId,proto3" json:"from_id,omitempty"`
+	Seq           int64                  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	Round         int64                  `protobuf:"varint,3,opt,name=round,proto3" json:"round,omitempty"`
+	Value         []byte                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Instance      *InstanceID            `protobuf:"bytes,5,opt,name=instance,proto3" json:"instance,omitempty"`
+	Deps          []*InstanceID          `protobuf:"bytes,6,rep,name=deps,proto3" json:"deps,omitempty"`
+	KeyId         uint32                 `protobuf:"varint,7,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Mac           []byte                 `protobuf:"bytes,8,opt,name=mac,proto3" json:"mac,omitempty"`
+	ClusterId     string                 `protobuf:"bytes,9,opt,name=cluster_id,json=clusterId,proto3" json:"cluster_id,omitempty"`
+	Epoch         int64                  `protobuf:"varint,10,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	TraceParent   string                 `protobuf:"bytes,11,opt,name=trace_parent,json=traceParent,proto3" json:"trace_parent,omitempty"`
+	TraceState    string                 `protobuf:"bytes,12,opt,name=trace_state,json=traceState,proto3" json:"trace_state,omitempty"`
+	Forget        int64                  `protobuf:"varint,13,opt,name=forget,proto3" json:"forget,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Request) Reset() {
+	*x = Request{}
+	mi := &file_paxos_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Request) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Request) ProtoMessage() {}
+
+func (x *Request) ProtoReflect() protoreflect.Message {
+	mi := &file_paxos_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Request.ProtoReflect.Descriptor instead.
+func (*Request) Descriptor() ([]byte, []int) {
+	return file_paxos_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Request) GetFromId() int64 {
+	if x != nil {
+		return x.FromId
+	}
+	return 0
+}
+
+func (x *Request) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Request) GetRound() int64 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *Request) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Request) GetInstance() *InstanceID {
+	if x != nil {
+		return x.Instance
+	}
+	return nil
+}
+
+func (x *Request) GetDeps() []*InstanceID {
+	if x != nil {
+		return x.Deps
+	}
+	return nil
+}
+
+func (x *Request) GetKeyId() uint32 {
+	if x != nil {
+		return x.KeyId
+	}
+	return 0
+}
+
+func (x *Request) GetMac() []byte {
+	if x != nil {
+		return x.Mac
+	}
+	return nil
+}
+
+func (x *Request) GetClusterId() string {
+	if x != nil {
+		return x.ClusterId
+	}
+	return ""
+}
+
+func (x *Request) GetEpoch() int64 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Request) GetTraceParent() string {
+	if x != nil {
+		return x.TraceParent
+	}
+	return ""
+}
+
+func (x *Request) GetTraceState() string {
+	if x != nil {
+		return x.TraceState
+	}
+	return ""
+}
+
+func (x *Request) GetForget() int64 {
+	if x != nil {
+		return x.Forget
+	}
+	return 0
+}
+
+type Response struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            bool                   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Decided       bool                   `protobuf:"varint,2,opt,name=decided,proto3" json:"decided,omitempty"`
+	Round         int64                  `protobuf:"varint,3,opt,name=round,proto3" json:"round,omitempty"`
+	Value         []byte                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	Seq           int64                  `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"`
+	Deps          []*InstanceID          `protobuf:"bytes,6,rep,name=deps,proto3" json:"deps,omitempty"`
+	KeyId         uint32                 `protobuf:"varint,7,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	Mac           []byte                 `protobuf:"bytes,8,opt,name=mac,proto3" json:"mac,omitempty"`
+	Forget        int64                  `protobuf:"varint,9,opt,name=forget,proto3" json:"forget,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Response) Reset() {
+	*x = Response{}
+	mi := &file_paxos_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Response) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Response) ProtoMessage() {}
+
+func (x *Response) ProtoReflect() protoreflect.Message {
+	mi := &file_paxos_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Response.ProtoReflect.Descriptor instead.
+func (*Response) Descriptor() ([]byte, []int) {
+	return file_paxos_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Response) GetOk() bool {
+	if x != nil {
+		return x.Ok
+	}
+	return false
+}
+
+func (x *Response) GetDecided() bool {
+	if x != nil {
+		return x.Decided
+	}
+	return false
+}
+
+func (x *Response) GetRound() int64 {
+	if x != nil {
+		return x.Round
+	}
+	return 0
+}
+
+func (x *Response) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Response) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Response) GetDeps() []*InstanceID {
+	if x != nil {
+		return x.Deps
+	}
+	return nil
+}
+
+func (x *Response) GetKeyId() uint32 {
+	if x != nil {
+		return x.KeyId
+	}
+	return 0
+}
+
+func (x *Response) GetMac() []byte {
+	if x != nil {
+		return x.Mac
+	}
+	return nil
+}
+
+func (x *Response) GetForget() int64 {
+	if x != nil {
+		return x.Forget
+	}
+	return 0
+}
+
+type Call struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Method        string                 `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	Request       *Request               `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Call) Reset() {
+	*x = Call{}
+	mi := &file_paxos_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Call) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Call) ProtoMessage() {}
+
+func (x *Call) ProtoReflect() protoreflect.Message {
+	mi := &file_paxos_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Call.ProtoReflect.Descriptor instead.
+func (*Call) Descriptor() ([]byte, []int) {
+	return file_paxos_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Call) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *Call) GetRequest() *Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+type SnapshotRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	From  int64                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	// request identifies the caller, so the snapshot is admitted like any
+	// other request.
+	Request       *Request `protobuf:"bytes,2,opt,name=request,proto3" json:"request,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_paxos_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paxos_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_paxos_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SnapshotRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *SnapshotRequest) GetRequest() *Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+type Instance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           int64                  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Value         []byte                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Instance) Reset() {
+	*x = Instance{}
+	mi := &file_paxos_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Instance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Instance) ProtoMessage() {}
+
+func (x *Instance) ProtoReflect() protoreflect.Message {
+	mi := &file_paxos_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Instance.ProtoReflect.Descriptor instead.
+func (*Instance) Descriptor() ([]byte, []int) {
+	return file_paxos_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Instance) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Instance) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+var File_paxos_proto protoreflect.FileDescriptor
+
+const file_paxos_proto_rawDesc = "" +
+	"\n" +
+	"\vpaxos.proto\x12\agopaxos\"B\n" +
+	"\n" +
+	"InstanceID\x12\x18\n" +
+	"\areplica\x18\x01 \x01(\x03R\areplica\x12\x1a\n" +
+	"\binstance\x18\x02 \x01(\x03R\binstance\"\xf4\x02\n" +
+	"\aRequest\x12\x17\n" +
+	"\afrom_id\x18\x01 \x01(\x03R\x06fromId\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x03R\x03seq\x12\x14\n" +
+	"\x05round\x18\x03 \x01(\x03R\x05round\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\fR\x05value\x12/\n" +
+	"\binstance\x18\x05 \x01(\v2\x13.gopaxos.InstanceIDR\binstance\x12'\n" +
+	"\x04deps\x18\x06 \x03(\v2\x13.gopaxos.InstanceIDR\x04deps\x12\x15\n" +
+	"\x06key_id\x18\a \x01(\rR\x05keyId\x12\x10\n" +
+	"\x03mac\x18\b \x01(\fR\x03mac\x12\x1d\n" +
+	"\n" +
+	"cluster_id\x18\t \x01(\tR\tclusterId\x12\x14\n" +
+	"\x05epoch\x18\n" +
+	" \x01(\x03R\x05epoch\x12!\n" +
+	"\ftrace_parent\x18\v \x01(\tR\vtraceParent\x12\x1f\n" +
+	"\vtrace_state\x18\f \x01(\tR\n" +
+	"traceState\x12\x16\n" +
+	"\x06forget\x18\r \x01(\x03R\x06forget\"\xdc\x01\n" +
+	"\bResponse\x12\x0e\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok\x12\x18\n" +
+	"\adecided\x18\x02 \x01(\bR\adecided\x12\x14\n" +
+	"\x05round\x18\x03 \x01(\x03R\x05round\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\fR\x05value\x12\x10\n" +
+	"\x03seq\x18\x05 \x01(\x03R\x03seq\x12'\n" +
+	"\x04deps\x18\x06 \x03(\v2\x13.gopaxos.InstanceIDR\x04deps\x12\x15\n" +
+	"\x06key_id\x18\a \x01(\rR\x05keyId\x12\x10\n" +
+	"\x03mac\x18\b \x01(\fR\x03mac\x12\x16\n" +
+	"\x06forget\x18\t \x01(\x03R\x06forget\"J\n" +
+	"\x04Call\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12*\n" +
+	"\arequest\x18\x02 \x01(\v2\x10.gopaxos.RequestR\arequest\"Q\n" +
+	"\x0fSnapshotRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x03R\x04from\x12*\n" +
+	"\arequest\x18\x02 \x01(\v2\x10.gopaxos.RequestR\arequest\"2\n" +
+	"\bInstance\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x03R\x03seq\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\fR\x05value2\xad\x02\n" +
+	"\x05Paxos\x12.\n" +
+	"\aPrepare\x12\x10.gopaxos.Request\x1a\x11.gopaxos.Response\x12-\n" +
+	"\x06Accept\x12\x10.gopaxos.Request\x1a\x11.gopaxos.Response\x12.\n" +
+	"\aDecided\x12\x10.gopaxos.Request\x1a\x11.gopaxos.Response\x12.\n" +
+	"\aCatchUp\x12\x10.gopaxos.Request\x1a\x11.gopaxos.Response\x12*\n" +
+	"\x06Invoke\x12\r.gopaxos.Call\x1a\x11.gopaxos.Response\x129\n" +
+	"\bSnapshot\x12\x18.gopaxos.SnapshotRequest\x1a\x11.gopaxos.Instance0\x01B6Z4github.com/yaoshengzhe/gopaxos/grpctransport/paxospbb\x06proto3"
+
+var (
+	file_paxos_proto_rawDescOnce sync.Once
+	file_paxos_proto_rawDescData []byte
+)
+
+func file_paxos_proto_rawDescGZIP() []byte {
+	file_paxos_proto_rawDescOnce.Do(func() {
+		file_paxos_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_paxos_proto_rawDesc), len(file_paxos_proto_rawDesc)))
+	})
+	return file_paxos_proto_rawDescData
+}
+
+var file_paxos_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_paxos_proto_goTypes = []any{
+	(*InstanceID)(nil),      // 0: gopaxos.InstanceID
+	(*Request)(nil),         // 1: gopaxos.Request
+	(*Response)(nil),        // 2: gopaxos.Response
+	(*Call)(nil),            // 3: gopaxos.Call
+	(*SnapshotRequest)(nil), // 4: gopaxos.SnapshotRequest
+	(*Instance)(nil),        // 5: gopaxos.Instance
+}
+var file_paxos_proto_depIdxs = []int32{
+	0,  // 0: gopaxos.Request.instance:type_name -> gopaxos.InstanceID
+	0,  // 1: gopaxos.Request.deps:type_name -> gopaxos.InstanceID
+	0,  // 2: gopaxos.Response.deps:type_name -> gopaxos.InstanceID
+	1,  // 3: gopaxos.Call.request:type_name -> gopaxos.Request
+	1,  // 4: gopaxos.SnapshotRequest.request:type_name -> gopaxos.Request
+	1,  // 5: gopaxos.Paxos.Prepare:input_type -> gopaxos.Request
+	1,  // 6: gopaxos.Paxos.Accept:input_type -> gopaxos.Request
+	1,  // 7: gopaxos.Paxos.Decided:input_type -> gopaxos.Request
+	1,  // 8: gopaxos.Paxos.CatchUp:input_type -> gopaxos.Request
+	3,  // 9: gopaxos.Paxos.Invoke:input_type -> gopaxos.Call
+	4,  // 10: gopaxos.Paxos.Snapshot:input_type -> gopaxos.SnapshotRequest
+	2,  // 11: gopaxos.Paxos.Prepare:output_type -> gopaxos.Response
+	2,  // 12: gopaxos.Paxos.Accept:output_type -> gopaxos.Response
+	2,  // 13: gopaxos.Paxos.Decided:output_type -> gopaxos.Response
+	2,  // 14: gopaxos.Paxos.CatchUp:output_type -> gopaxos.Response
+	2,  // 15: gopaxos.Paxos.Invoke:output_type -> gopaxos.Response
+	5,  // 16: gopaxos.Paxos.Snapshot:output_type -> gopaxos.Instance
+	11, // [11:17] is the sub-list for method output_type
+	5,  // [5:11] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_paxos_proto_init() }
+func file_paxos_proto_init() {
+	if File_paxos_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_paxos_proto_rawDesc), len(file_paxos_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_paxos_proto_goTypes,
+		DependencyIndexes: file_paxos_proto_depIdxs,
+		MessageInfos:      file_paxos_proto_msgTypes,
+	}.Build()
+	File_paxos_proto = out.File
+	file_paxos_proto_goTypes = nil
+	file_paxos_proto_depIdxs = nil
+}
@@ -0,0 +1,333 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: paxos.proto
+
+package paxospb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Paxos_Prepare_FullMethodName  = "/gopaxos.Paxos/Prepare"
+	Paxos_Accept_FullMethodName   = "/gopaxos.Paxos/Accept"
+	Paxos_Decided_FullMethodName  = "/gopaxos.Paxos/Decided"
+	Paxos_CatchUp_FullMethodName  = "/gopaxos.Paxos/CatchUp"
+	Paxos_Invoke_FullMethodName   = "/gopaxos.Paxos/Invoke"
+	Paxos_Snapshot_FullMethodName = "/gopaxos.Paxos/Snapshot"
+)
+
+// PaxosClient is the client API for Paxos service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Paxos carries the RPCs between gopaxos peers. Values are gob-encoded, as
+// on the net/rpc transport, so any gob-registered Go value can be decided.
+type PaxosClient interface {
+	// Prepare is Handler.OnReceiveFastPrepare.
+	Prepare(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// Accept is Handler.OnReceiveFastAccept.
+	Accept(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// Decided is Handler.OnReceiveDecision.
+	Decided(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// CatchUp is Handler.OnReceiveCatchUp.
+	CatchUp(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	// Invoke calls any other Handler method by name.
+	Invoke(ctx context.Context, in *Call, opts ...grpc.CallOption) (*Response, error)
+	// Snapshot streams every decided instance at or above from.
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Instance], error)
+}
+
+type paxosClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPaxosClient(cc grpc.ClientConnInterface) PaxosClient {
+	return &paxosClient{cc}
+}
+
+func (c *paxosClient) Prepare(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Paxos_Prepare_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paxosClient) Accept(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Paxos_Accept_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paxosClient) Decided(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Paxos_Decided_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paxosClient) CatchUp(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Paxos_CatchUp_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paxosClient) Invoke(ctx context.Context, in *Call, opts ...grpc.CallOption) (*Response, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Response)
+	err := c.cc.Invoke(ctx, Paxos_Invoke_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paxosClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Instance], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Paxos_ServiceDesc.Streams[0], Paxos_Snapshot_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SnapshotRequest, Instance]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Paxos_SnapshotClient = grpc.ServerStreamingClient[Instance]
+
+// PaxosServer is the server API for Paxos service.
+// All implementations must embed UnimplementedPaxosServer
+// for forward compatibility.
+//
+// Paxos carries the RPCs between gopaxos peers. Values are gob-encoded, as
+// on the net/rpc transport, so any gob-registered Go value can be decided.
+type PaxosServer interface {
+	// Prepare is Handler.OnReceiveFastPrepare.
+	Prepare(context.Context, *Request) (*Response, error)
+	// Accept is Handler.OnReceiveFastAccept.
+	Accept(context.Context, *Request) (*Response, error)
+	// Decided is Handler.OnReceiveDecision.
+	Decided(context.Context, *Request) (*Response, error)
+	// CatchUp is Handler.OnReceiveCatchUp.
+	CatchUp(context.Context, *Request) (*Response, error)
+	// Invoke calls any other Handler method by name.
+	Invoke(context.Context, *Call) (*Response, error)
+	// Snapshot streams every decided instance at or above from.
+	Snapshot(*SnapshotRequest, grpc.ServerStreamingServer[Instance]) error
+	mustEmbedUnimplementedPaxosServer()
+}
+
+// UnimplementedPaxosServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPaxosServer struct{}
+
+func (UnimplementedPaxosServer) Prepare(context.Context, *Request) (*Response, error) {
+	return nil, status.Error(codes.Unimplemented, "method Prepare not implemented")
+}
+func (UnimplementedPaxosServer) Accept(context.Context, *Request) (*Response, error) {
+	return nil, status.Error(codes.Unimplemented, "method Accept not implemented")
+}
+func (UnimplementedPaxosServer) Decided(context.Context, *Request) (*Response, error) {
+	return nil, status.Error(codes.Unimplemented, "method Decided not implemented")
+}
+func (UnimplementedPaxosServer) CatchUp(context.Context, *Request) (*Response, error) {
+	return nil, status.Error(codes.Unimplemented, "method CatchUp not implemented")
+}
+func (UnimplementedPaxosServer) Invoke(context.Context, *Call) (*Response, error) {
+	return nil, status.Error(codes.Unimplemented, "method Invoke not implemented")
+}
+func (UnimplementedPaxosServer) Snapshot(*SnapshotRequest, grpc.ServerStreamingServer[Instance]) error {
+	return status.Error(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedPaxosServer) mustEmbedUnimplementedPaxosServer() {}
+func (UnimplementedPaxosServer) testEmbeddedByValue()               {}
+
+// UnsafePaxosServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PaxosServer will
+// result in compilation errors.
+type UnsafePaxosServer interface {
+	mustEmbedUnimplementedPaxosServer()
+}
+
+func RegisterPaxosServer(s grpc.ServiceRegistrar, srv PaxosServer) {
+	// If the following call panics, it indicates UnimplementedPaxosServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Paxos_ServiceDesc, srv)
+}
+
+func _Paxos_Prepare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaxosServer).Prepare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Paxos_Prepare_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaxosServer).Prepare(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Paxos_Accept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaxosServer).Accept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Paxos_Accept_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaxosServer).Accept(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Paxos_Decided_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaxosServer).Decided(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Paxos_Decided_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaxosServer).Decided(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Paxos_CatchUp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaxosServer).CatchUp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Paxos_CatchUp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaxosServer).CatchUp(ctx, req.(*Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Paxos_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Call)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaxosServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Paxos_Invoke_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaxosServer).Invoke(ctx, req.(*Call))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Paxos_Snapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PaxosServer).Snapshot(m, &grpc.GenericServerStream[SnapshotRequest, Instance]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Paxos_SnapshotServer = grpc.ServerStreamingServer[Instance]
+
+// Paxos_ServiceDesc is the grpc.ServiceDesc for Paxos service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Paxos_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gopaxos.Paxos",
+	HandlerType: (*PaxosServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Prepare",
+			Handler:    _Paxos_Prepare_Handler,
+		},
+		{
+			MethodName: "Accept",
+			Handler:    _Paxos_Accept_Handler,
+		},
+		{
+			MethodName: "Decided",
+			Handler:    _Paxos_Decided_Handler,
+		},
+		{
+			MethodName: "CatchUp",
+			Handler:    _Paxos_CatchUp_Handler,
+		},
+		{
+			MethodName: "Invoke",
+			Handler:    _Paxos_Invoke_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Snapshot",
+			Handler:       _Paxos_Snapshot_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "paxos.proto",
+}
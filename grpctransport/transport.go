@@ -0,0 +1,480 @@
+// Package grpctransport carries gopaxos RPCs over gRPC instead of net/rpc.
+// Plug it in with gopaxos.WithTransport(grpctransport.New()); every peer of
+// the cluster must do the same.
+//
+// The core Paxos RPCs, the prepare and accept of Start and StartFast, the
+// decision and catch-up, have their own gRPC methods; the other Handler
+// methods go through Invoke by name. Values are gob-encoded as on the
+// net/rpc transport.
+package grpctransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/yaoshengzhe/gopaxos"
+	"github.com/yaoshengzhe/gopaxos/grpctransport/paxospb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Transport is a gopaxos.Transport over gRPC. A Transport serves one peer.
+type Transport struct {
+	listen     func(addr string) (net.Listener, error)
+	dial       func(ctx context.Context, addr string) (net.Conn, error)
+	serverOpts []grpc.ServerOption
+	dialOpts   []grpc.DialOption
+	mutualTLS  bool // check client certificates against FromID
+
+	mu      sync.Mutex
+	server  *grpc.Server
+	handler *gopaxos.Handler // of the peer served
+	conns   map[string]*grpc.ClientConn
+}
+
+// Option configures a Transport.
+type Option func(*Transport)
+
+// WithListener replaces net.Listen("tcp", addr) as the way Serve listens,
+// e.g. with a bufconn listener in tests.
+func WithListener(listen func(addr string) (net.Listener, error)) Option {
+	return func(t *Transport) {
+		t.listen = listen
+	}
+}
+
+// WithDialer replaces TCP as the way peers are dialed.
+func WithDialer(dial func(ctx context.Context, addr string) (net.Conn, error)) Option {
+	return func(t *Transport) {
+		t.dial = dial
+	}
+}
+
+// WithServerOptions passes opts to grpc.NewServer.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(t *Transport) {
+		t.serverOpts = append(t.serverOpts, opts...)
+	}
+}
+
+// WithDialOptions passes opts to grpc.NewClient. Without transport
+// credentials among them, connections are insecure.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(t *Transport) {
+		t.dialOpts = append(t.dialOpts, opts...)
+	}
+}
+
+// WithTLS secures the transport with mutual TLS, like gopaxos.WithTLS does
+// for net/rpc: config must hold this peer's certificate, and RootCAs and
+// ClientCAs that verify the other peers'. Each request is only served if
+// the client's certificate identifies peers[FromID], as
+// gopaxos.Handler.Authorize checks.
+func WithTLS(config *tls.Config) Option {
+	return func(t *Transport) {
+		server := config.Clone()
+		server.ClientAuth = tls.RequireAndVerifyClientCert
+		t.serverOpts = append(t.serverOpts, grpc.Creds(credentials.NewTLS(server)))
+		t.dialOpts = append(t.dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+		t.mutualTLS = true
+	}
+}
+
+// New returns a Transport.
+func New(opts ...Option) *Transport {
+	t := &Transport{
+		listen: func(addr string) (net.Listener, error) { return net.Listen("tcp", addr) },
+		conns:  make(map[string]*grpc.ClientConn),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Serve answers the RPCs addressed to peer with h.
+func (t *Transport) Serve(peer string, h *gopaxos.Handler) error {
+	l, err := t.listen(peerAddr(peer))
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(t.serverOpts...)
+	paxospb.RegisterPaxosServer(server, newService(h, t.mutualTLS))
+	t.mu.Lock()
+	t.server, t.handler = server, h
+	t.mu.Unlock()
+	go server.Serve(l)
+	return nil
+}
+
+// Call invokes method on peer.
+func (t *Transport) Call(ctx context.Context, peer, method string, req *gopaxos.Request, resp *gopaxos.Response) error {
+	client, err := t.client(peer)
+	if err != nil {
+		return err
+	}
+	in, err := toPB(req)
+	if err != nil {
+		return err
+	}
+	var out *paxospb.Response
+	switch method {
+	case "Handler.OnReceiveFastPrepare":
+		out, err = client.Prepare(ctx, in)
+	case "Handler.OnReceiveFastAccept":
+		out, err = client.Accept(ctx, in)
+	case "Handler.OnReceiveDecision":
+		out, err = client.Decided(ctx, in)
+	case "Handler.OnReceiveCatchUp":
+		out, err = client.CatchUp(ctx, in)
+	default:
+		out, err = client.Invoke(ctx, &paxospb.Call{Method: method, Request: in})
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	r, err := fromPBResponse(out)
+	if err != nil {
+		return err
+	}
+	*resp = *r
+	return nil
+}
+
+// Snapshot streams every instance peer decided at or above from into recv,
+// in seq order, e.g. to gopaxos.Paxos.Install. The request is sent as the
+// peer t serves, so t must serve one.
+func (t *Transport) Snapshot(ctx context.Context, peer string, from int, recv func(seq int, v gopaxos.Value)) error {
+	t.mu.Lock()
+	h := t.handler
+	t.mu.Unlock()
+	if h == nil {
+		return fmt.Errorf("grpctransport: snapshot from %s: transport serves no peer", peer)
+	}
+	client, err := t.client(peer)
+	if err != nil {
+		return err
+	}
+	in, err := toPB(h.SignRequest(snapshotMethod, &gopaxos.Request{Seq: from}))
+	if err != nil {
+		return err
+	}
+	stream, err := client.Snapshot(ctx, &paxospb.SnapshotRequest{From: int64(from), Request: in})
+	if err != nil {
+		return err
+	}
+	for {
+		inst, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		v, err := decodeValue(inst.Value)
+		if err != nil {
+			return err
+		}
+		recv(int(inst.Seq), v)
+	}
+}
+
+// Close stops the server and closes every connection.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.server != nil {
+		t.server.Stop()
+	}
+	for peer, conn := range t.conns {
+		conn.Close()
+		delete(t.conns, peer)
+	}
+	return nil
+}
+
+// client returns the client of peer, creating it on first use. gRPC
+// connects lazily and reconnects with backoff on its own.
+func (t *Transport) client(peer string) (paxospb.PaxosClient, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if conn, ok := t.conns[peer]; ok {
+		return paxospb.NewPaxosClient(conn), nil
+	}
+	// options given later override the insecure default.
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	target := "passthrough:///" + peerAddr(peer)
+	if t.dial != nil {
+		opts = append(opts, grpc.WithContextDialer(t.dial))
+	}
+	conn, err := grpc.NewClient(target, append(opts, t.dialOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+	t.conns[peer] = conn
+	return paxospb.NewPaxosClient(conn), nil
+}
+
+// peerAddr strips the RPC path net/rpc needs from a gopaxos peer address.
+func peerAddr(peer string) string {
+	addr, _, _ := strings.Cut(peer, "/")
+	return addr
+}
+
+// service serves a gopaxos.Handler over gRPC.
+type service struct {
+	paxospb.UnimplementedPaxosServer
+	h         *gopaxos.Handler
+	methods   map[string]func(*gopaxos.Request, *gopaxos.Response) error
+	mutualTLS bool
+}
+
+// newService finds the RPC methods of h the way net/rpc does: exported
+// methods of the form func(*Request, *Response) error.
+func newService(h *gopaxos.Handler, mutualTLS bool) *service {
+	s := &service{
+		h:         h,
+		methods:   make(map[string]func(*gopaxos.Request, *gopaxos.Response) error),
+		mutualTLS: mutualTLS,
+	}
+	v := reflect.ValueOf(h)
+	for i := 0; i < v.NumMethod(); i++ {
+		if f, ok := v.Method(i).Interface().(func(*gopaxos.Request, *gopaxos.Response) error); ok {
+			s.methods["Handler."+v.Type().Method(i).Name] = f
+		}
+	}
+	return s
+}
+
+func (s *service) call(ctx context.Context, method string, in *paxospb.Request) (*paxospb.Response, error) {
+	f, ok := s.methods[method]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "no method %s", method)
+	}
+	req, err := fromPB(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.authorize(ctx, req.FromID); err != nil {
+		return nil, err
+	}
+	if err := s.h.Admit(method, req); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	end := s.h.StartSpan(method, req)
+	var resp gopaxos.Response
+	err = f(req, &resp)
+	end(err)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	s.h.SignResponse(method, req, &resp)
+	out, err := toPBResponse(&resp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return out, nil
+}
+
+// authorize checks the client certificate of the call against from.
+func (s *service) authorize(ctx context.Context, from int) error {
+	if !s.mutualTLS {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "no peer")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	if err := s.h.Authorize(info.State.PeerCertificates[0], from); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+func (s *service) Prepare(ctx context.Context, in *paxospb.Request) (*paxospb.Response, error) {
+	return s.call(ctx, "Handler.OnReceiveFastPrepare", in)
+}
+
+func (s *service) Accept(ctx context.Context, in *paxospb.Request) (*paxospb.Response, error) {
+	return s.call(ctx, "Handler.OnReceiveFastAccept", in)
+}
+
+func (s *service) Decided(ctx context.Context, in *paxospb.Request) (*paxospb.Response, error) {
+	return s.call(ctx, "Handler.OnReceiveDecision", in)
+}
+
+func (s *service) CatchUp(ctx context.Context, in *paxospb.Request) (*paxospb.Response, error) {
+	return s.call(ctx, "Handler.OnReceiveCatchUp", in)
+}
+
+func (s *service) Invoke(ctx context.Context, in *paxospb.Call) (*paxospb.Response, error) {
+	return s.call(ctx, in.Method, in.Request)
+}
+
+// snapshotMethod names the Snapshot RPC in MACs and Admit.
+const snapshotMethod = "Handler.Snapshot"
+
+func (s *service) Snapshot(in *paxospb.SnapshotRequest, stream paxospb.Paxos_SnapshotServer) error {
+	req, err := fromPB(in.GetRequest())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.authorize(stream.Context(), req.FromID); err != nil {
+		return err
+	}
+	if err := s.h.Admit(snapshotMethod, req); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return s.h.Snapshot(int(in.From), func(seq int, v gopaxos.Value) error {
+		b, err := encodeValue(v)
+		if err != nil {
+			return err
+		}
+		return stream.Send(&paxospb.Instance{Seq: int64(seq), Value: b})
+	})
+}
+
+// box lets gob encode a Value of any registered type.
+type box struct {
+	V gopaxos.Value
+}
+
+func encodeValue(v gopaxos.Value) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(box{v}); err != nil {
+		return nil, fmt.Errorf("encode %T: %v", v, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValue(b []byte) (gopaxos.Value, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var x box
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&x); err != nil {
+		return nil, err
+	}
+	return x.V, nil
+}
+
+func toPBIDs(ids []gopaxos.InstanceID) []*paxospb.InstanceID {
+	var out []*paxospb.InstanceID
+	for _, id := range ids {
+		out = append(out, &paxospb.InstanceID{Replica: int64(id.Replica), Instance: int64(id.Instance)})
+	}
+	return out
+}
+
+func fromPBIDs(ids []*paxospb.InstanceID) []gopaxos.InstanceID {
+	var out []gopaxos.InstanceID
+	for _, id := range ids {
+		out = append(out, gopaxos.InstanceID{Replica: int(id.GetReplica()), Instance: int(id.GetInstance())})
+	}
+	return out
+}
+
+func toPB(req *gopaxos.Request) (*paxospb.Request, error) {
+	v, err := encodeValue(req.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &paxospb.Request{
+		FromId:      int64(req.FromID),
+		Seq:         int64(req.Seq),
+		Round:       int64(req.Round),
+		Value:       v,
+		Instance:    toPBIDs([]gopaxos.InstanceID{req.Instance})[0],
+		Deps:        toPBIDs(req.Deps),
+		KeyId:       req.KeyID,
+		Mac:         req.MAC,
+		ClusterId:   req.ClusterID,
+		Epoch:       int64(req.Epoch),
+		TraceParent: req.TraceParent,
+		TraceState:  req.TraceState,
+		Forget:      int64(req.Forget),
+	}, nil
+}
+
+func fromPB(in *paxospb.Request) (*gopaxos.Request, error) {
+	v, err := decodeValue(in.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	req := &gopaxos.Request{
+		FromID:      int(in.GetFromId()),
+		Seq:         int(in.GetSeq()),
+		Round:       int(in.GetRound()),
+		Value:       v,
+		Deps:        fromPBIDs(in.GetDeps()),
+		KeyID:       in.GetKeyId(),
+		MAC:         in.GetMac(),
+		ClusterID:   in.GetClusterId(),
+		Epoch:       int(in.GetEpoch()),
+		TraceParent: in.GetTraceParent(),
+		TraceState:  in.GetTraceState(),
+		Forget:      int(in.GetForget()),
+	}
+	if in.GetInstance() != nil {
+		req.Instance = fromPBIDs([]*paxospb.InstanceID{in.GetInstance()})[0]
+	}
+	return req, nil
+}
+
+func toPBResponse(resp *gopaxos.Response) (*paxospb.Response, error) {
+	v, err := encodeValue(resp.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &paxospb.Response{
+		Ok:      resp.OK,
+		Decided: resp.Decided,
+		Round:   int64(resp.Round),
+		Value:   v,
+		Seq:     int64(resp.Seq),
+		Deps:    toPBIDs(resp.Deps),
+		KeyId:   resp.KeyID,
+		Mac:     resp.MAC,
+		Forget:  int64(resp.Forget),
+	}, nil
+}
+
+func fromPBResponse(out *paxospb.Response) (*gopaxos.Response, error) {
+	v, err := decodeValue(out.GetValue())
+	if err != nil {
+		return nil, err
+	}
+	return &gopaxos.Response{
+		OK:      out.GetOk(),
+		Decided: out.GetDecided(),
+		Round:   int(out.GetRound()),
+		Value:   v,
+		Seq:     int(out.GetSeq()),
+		Deps:    fromPBIDs(out.GetDeps()),
+		KeyID:   out.GetKeyId(),
+		MAC:     out.GetMac(),
+		Forget:  int(out.GetForget()),
+	}, nil
+}
@@ -0,0 +1,326 @@
+package grpctransport
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos"
+	"github.com/yaoshengzhe/gopaxos/grpctransport/paxospb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufnet is an in-memory network of bufconn listeners keyed by address.
+type bufnet struct {
+	mu        sync.Mutex
+	listeners map[string]*bufconn.Listener
+}
+
+func (n *bufnet) listen(addr string) (net.Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	l := bufconn.Listen(1 << 20)
+	n.listeners[addr] = l
+	return l, nil
+}
+
+func (n *bufnet) dial(ctx context.Context, addr string) (net.Conn, error) {
+	n.mu.Lock()
+	l, ok := n.listeners[addr]
+	n.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no listener at %s", addr)
+	}
+	return l.DialContext(ctx)
+}
+
+func waitDecided(t *testing.T, pxa []*gopaxos.Paxos, seq int, want gopaxos.Value) {
+	for iters := 0; iters < 50; iters++ {
+		n := 0
+		for _, px := range pxa {
+			if decided, v := px.Status(seq); decided {
+				if v != want {
+					t.Fatalf("Status(%d) = %v, want: %v", seq, v, want)
+				}
+				n++
+			}
+		}
+		if n == len(pxa) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("instance %d not decided on every peer", seq)
+}
+
+func TestGRPCTransport(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*gopaxos.Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	ts := make([]*Transport, npaxos)
+	network := &bufnet{listeners: make(map[string]*bufconn.Listener)}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = fmt.Sprintf("bufnet-%d:0/paxos", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		ts[i] = New(WithListener(network.listen), WithDialer(network.dial))
+		pxa[i] = gopaxos.Make(pxh, i, gopaxos.WithTransport(ts[i]))
+	}
+	defer func() {
+		for _, px := range pxa {
+			px.Kill()
+		}
+	}()
+
+	fmt.Println("Test: gRPC transport over bufconn ...")
+
+	// StartFast goes through Accept; decisions through Decided.
+	for seq := 0; seq < 3; seq++ {
+		pxa[seq].StartFast(seq, fmt.Sprintf("v%d", seq))
+		waitDecided(t, pxa, seq, fmt.Sprintf("v%d", seq))
+	}
+
+	got := make(map[int]gopaxos.Value)
+	err := ts[1].Snapshot(context.Background(), pxh[0], 1, func(seq int, v gopaxos.Value) {
+		got[seq] = v
+	})
+	if err != nil || len(got) != 2 || got[1] != "v1" || got[2] != "v2" {
+		t.Fatalf("Snapshot(1) = %v, %v, want: map[1:v1 2:v2], nil", got, err)
+	}
+
+	// a vote cast through the Prepare and Accept methods binds the
+	// proposer of Start.
+	h := gopaxos.NewHandler(pxa[2])
+	for _, peer := range pxh[:2] {
+		client, err := ts[2].client(peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, call := range []struct {
+			method string
+			rpc    func(context.Context, *paxospb.Request, ...grpc.CallOption) (*paxospb.Response, error)
+		}{
+			{"Handler.OnReceiveFastPrepare", client.Prepare},
+			{"Handler.OnReceiveFastAccept", client.Accept},
+		} {
+			in, err := toPB(h.SignRequest(call.method, &gopaxos.Request{Seq: 3, Round: 10, Value: "typed"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := call.rpc(context.Background(), in)
+			if err != nil || !out.Ok {
+				t.Fatalf("%s to %s = %v, %v, want: true, nil", call.method, peer, out.GetOk(), err)
+			}
+		}
+	}
+	pxa[2].Start(3, "other")
+	waitDecided(t, pxa, 3, "typed")
+
+	// so does a fast round vote cast through Accept.
+	for _, peer := range pxh {
+		client, err := ts[2].client(peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := toPB(h.SignRequest("Handler.OnReceiveFastAccept", &gopaxos.Request{Seq: 4, Value: "fast"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := client.Accept(context.Background(), in); err != nil || !out.Ok {
+			t.Fatalf("fast Accept to %s = %v, %v, want: true, nil", peer, out.GetOk(), err)
+		}
+	}
+	pxa[2].Start(4, "other")
+	waitDecided(t, pxa, 4, "fast")
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGRPCCodec(t *testing.T) {
+	fmt.Println("Test: gRPC messages round-trip Requests ...")
+
+	req := &gopaxos.Request{
+		FromID:   1,
+		Seq:      2,
+		Round:    3,
+		Value:    gopaxos.CStruct{"a", "b"},
+		Instance: gopaxos.InstanceID{Replica: 1, Instance: 4},
+		Deps:     []gopaxos.InstanceID{{Replica: 0, Instance: 1}},
+	}
+	in, err := toPB(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := fromPB(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(out) != fmt.Sprint(req) {
+		t.Fatalf("round trip = %v, want: %v", out, req)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+// tlsConfigs returns a TLS config for each host, with certificates issued
+// by a fresh CA.
+func tlsConfigs(t *testing.T, hosts []string) []*tls.Config {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gopaxos test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	var configs []*tls.Config
+	for i, host := range hosts {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: host},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			DNSNames:     []string{host},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs = append(configs, &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+			RootCAs:      pool,
+			ClientCAs:    pool,
+		})
+	}
+	return configs
+}
+
+func TestGRPCMutualTLS(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*gopaxos.Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	hosts := make([]string, npaxos)
+	network := &bufnet{listeners: make(map[string]*bufconn.Listener)}
+	for i := 0; i < npaxos; i++ {
+		hosts[i] = fmt.Sprintf("peer%d.test", i)
+		pxh[i] = hosts[i] + ":0/paxos"
+	}
+	configs := tlsConfigs(t, hosts)
+	for i := 0; i < npaxos; i++ {
+		tr := New(WithListener(network.listen), WithDialer(network.dial), WithTLS(configs[i]))
+		pxa[i] = gopaxos.Make(pxh, i, gopaxos.WithTransport(tr))
+	}
+	defer func() {
+		for _, px := range pxa {
+			px.Kill()
+		}
+	}()
+
+	fmt.Println("Test: gRPC transport with mutual TLS ...")
+
+	pxa[0].StartFast(0, "hello")
+	waitDecided(t, pxa, 0, "hello")
+
+	// peer 2's certificate may only speak for peer 2.
+	impostor := New(WithDialer(network.dial), WithTLS(configs[2]))
+	defer impostor.Close()
+	req := &gopaxos.Request{FromID: 1, Seq: 1, Value: "x", ClusterID: pxa[0].ClusterID()}
+	err := impostor.Call(context.Background(), pxh[0], "Handler.OnReceiveFastAccept", req, &gopaxos.Response{})
+	if err == nil || !strings.Contains(err.Error(), "peer 1") {
+		t.Fatalf("request of peer 2 as peer 1 = %v, want: rejected", err)
+	}
+	req.FromID = 2
+	if err := impostor.Call(context.Background(), pxh[0], "Handler.OnReceiveFastAccept", req, &gopaxos.Response{}); err != nil {
+		t.Fatalf("request of peer 2 = %v, want: nil", err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGRPCMessageAuthentication(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*gopaxos.Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	network := &bufnet{listeners: make(map[string]*bufconn.Listener)}
+	ts := make([]*Transport, npaxos)
+	key := gopaxos.Key{ID: 1, Secret: []byte("cluster secret key")}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = fmt.Sprintf("bufnet-%d:0/paxos", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		ts[i] = New(WithListener(network.listen), WithDialer(network.dial))
+		pxa[i] = gopaxos.Make(pxh, i, gopaxos.WithTransport(ts[i]), gopaxos.WithKeys(key))
+	}
+	defer func() {
+		for _, px := range pxa {
+			px.Kill()
+		}
+	}()
+
+	fmt.Println("Test: gRPC transport with message authentication ...")
+
+	pxa[0].StartFast(0, "hello")
+	waitDecided(t, pxa, 0, "hello")
+
+	// an unsigned request is rejected.
+	outsider := New(WithDialer(network.dial))
+	defer outsider.Close()
+	req := &gopaxos.Request{FromID: 1, Seq: 1, Value: "x"}
+	err := outsider.Call(context.Background(), pxh[0], "Handler.OnReceiveFastAccept", req, &gopaxos.Response{})
+	if err == nil || !strings.Contains(err.Error(), gopaxos.ErrBadMAC.Error()) {
+		t.Fatalf("unsigned request = %v, want: %v", err, gopaxos.ErrBadMAC)
+	}
+
+	// so is an unsigned snapshot, while a peer's is served.
+	client, err := outsider.client(pxh[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.Snapshot(context.Background(), &paxospb.SnapshotRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err == nil || !strings.Contains(err.Error(), gopaxos.ErrBadMAC.Error()) {
+		t.Fatalf("unsigned snapshot = %v, want: %v", err, gopaxos.ErrBadMAC)
+	}
+	got := make(map[int]gopaxos.Value)
+	err = ts[1].Snapshot(context.Background(), pxh[0], 0, func(seq int, v gopaxos.Value) {
+		got[seq] = v
+	})
+	if err != nil || len(got) != 1 || got[0] != "hello" {
+		t.Fatalf("Snapshot(0) = %v, %v, want: map[0:hello], nil", got, err)
+	}
+
+	fmt.Println("  ... Passed")
+}
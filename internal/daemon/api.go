@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos"
+	"github.com/yaoshengzhe/gopaxos/internal/seqrange"
+)
+
+// The client API proposes string values and reads the decided log:
+//
+//	POST /propose              {"value": "..."}, answers {"seq": N, "index": I}
+//	GET  /log/{seq}            the decision of seq, see below
+//	GET  /log?from=&to=        the decided instances in [from, to], by
+//	                           default the last maxLogRange up to Max()
+//
+// A value is proposed with Paxos.Submit and decided inside a
+// gopaxos.Batch: the answer of /propose is where it ended up. GET
+// /log/{seq} takes ?wait=DURATION to wait for seq to be decided, and
+// ?consistent=true to pass a read barrier first, so that a value decided
+// before the request is never reported as undecided. The barrier writes
+// nothing: it waits for the instances below it to be decided, and answers
+// 503 if the request ends first.
+
+// maxLogRange bounds the instances one GET /log request returns.
+const maxLogRange = 1000
+
+type proposal struct {
+	Value string `json:"value"`
+}
+
+type proposed struct {
+	Seq   int `json:"seq"`
+	Index int `json:"index"`
+}
+
+type entry struct {
+	Seq     int           `json:"seq"`
+	Decided bool          `json:"decided"`
+	Value   gopaxos.Value `json:"value,omitempty"`
+}
+
+func apiHandler(px *gopaxos.Paxos) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /propose", func(w http.ResponseWriter, r *http.Request) {
+		var p proposal
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			http.Error(w, "invalid proposal: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		select {
+		case res := <-px.Submit(p.Value):
+			if res.Err != nil {
+				http.Error(w, res.Err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, proposed{Seq: res.Seq, Index: res.Index})
+		case <-r.Context().Done():
+			http.Error(w, r.Context().Err().Error(), http.StatusServiceUnavailable)
+		}
+	})
+	mux.HandleFunc("GET /log/{seq}", func(w http.ResponseWriter, r *http.Request) {
+		seq, err := strconv.Atoi(r.PathValue("seq"))
+		if err != nil {
+			http.Error(w, "invalid seq: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		if s := r.URL.Query().Get("consistent"); s != "" {
+			consistent, err := strconv.ParseBool(s)
+			if err != nil {
+				http.Error(w, "invalid consistent: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if consistent {
+				barrier, err := px.ReadBarrier(ctx)
+				if err == nil {
+					err = px.WaitApplied(ctx, barrier)
+				}
+				if err != nil {
+					http.Error(w, "read barrier: "+err.Error(), http.StatusServiceUnavailable)
+					return
+				}
+			}
+		}
+		var wait time.Duration
+		if s := r.URL.Query().Get("wait"); s != "" {
+			if wait, err = time.ParseDuration(s); err != nil {
+				http.Error(w, "invalid wait: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		decided, v := waitDecided(ctx, px, seq, wait)
+		writeJSON(w, entry{Seq: seq, Decided: decided, Value: v})
+	})
+	mux.HandleFunc("GET /log", func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := seqrange.FromQuery(r, px.Min(), px.Max(), maxLogRange)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		entries := []entry{}
+		for seq := from; seq <= to; seq++ {
+			if decided, v := px.Status(seq); decided {
+				entries = append(entries, entry{Seq: seq, Decided: true, Value: v})
+			}
+		}
+		writeJSON(w, entries)
+	})
+	return mux
+}
+
+// waitDecided waits up to wait for seq to be decided, and returns its
+// status.
+func waitDecided(ctx context.Context, px *gopaxos.Paxos, seq int, wait time.Duration) (bool, gopaxos.Value) {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	for {
+		if decided, v := px.Status(seq); decided || ctx.Err() != nil {
+			return decided, v
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,231 @@
+// Package daemon runs a gopaxos peer as a server process, for paxosd and
+// paxosctl start.
+package daemon
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/yaoshengzhe/gopaxos"
+	"github.com/yaoshengzhe/gopaxos/grpctransport"
+)
+
+// Config is the config file of a peer. Load reads it as YAML, JSON or TOML
+// depending on the file extension; keys are the snake_case names of the
+// tags below in every format.
+type Config struct {
+	ID    int      `json:"id" yaml:"id" toml:"id"`
+	Peers []string `json:"peers" yaml:"peers" toml:"peers"` // ${HOSTNAME}:${PORT}/${RPC_PATH}
+
+	// Listen is the address of the HTTP server for the client API, /v1/,
+	// and the metrics, /metrics. Empty means no HTTP server.
+	Listen string `json:"listen" yaml:"listen" toml:"listen"`
+
+	// AdminListen is the address of the HTTP server for the admin handler,
+	// /admin/, which has no access control: bind it to an address only
+	// operators can reach, e.g. 127.0.0.1:8081. Empty means no admin
+	// server.
+	AdminListen string `json:"admin_listen" yaml:"admin_listen" toml:"admin_listen"`
+
+	// DataDir holds the acceptor state of the peer, synced before every
+	// answer to a prepare or accept, and the snapshot of the decided log
+	// written on graceful shutdown. Both are restored on start. Empty
+	// means nothing is kept, see Run.
+	DataDir string `json:"data_dir" yaml:"data_dir" toml:"data_dir"`
+
+	// Transport between peers: "rpc" (net/rpc, the default) or "grpc".
+	Transport string `json:"transport" yaml:"transport" toml:"transport"`
+	TLS       *TLS   `json:"tls" yaml:"tls" toml:"tls"`
+
+	RPCTimeout      Duration `json:"rpc_timeout" yaml:"rpc_timeout" toml:"rpc_timeout"`
+	IdleTimeout     Duration `json:"idle_timeout" yaml:"idle_timeout" toml:"idle_timeout"` // rpc transport only
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+
+	ClusterID  string   `json:"cluster_id" yaml:"cluster_id" toml:"cluster_id"`
+	Learners   []int    `json:"learners" yaml:"learners" toml:"learners"`
+	Alpha      int      `json:"alpha" yaml:"alpha" toml:"alpha"`
+	Mencius    bool     `json:"mencius" yaml:"mencius" toml:"mencius"`
+	Window     int      `json:"window" yaml:"window" toml:"window"`
+	BatchSize  int      `json:"batch_size" yaml:"batch_size" toml:"batch_size"`
+	BatchDelay Duration `json:"batch_delay" yaml:"batch_delay" toml:"batch_delay"`
+	Debug      bool     `json:"debug" yaml:"debug" toml:"debug"` // protocol traces, see Paxos.SetDebug
+
+	// InitialPeers is the number of peers the cluster started with, for a
+	// peer added by POST /admin/members whose Peers lists the added ones
+	// after them, see gopaxos.WithInitialPeers. 0 means all of Peers.
+	InitialPeers int `json:"initial_peers" yaml:"initial_peers" toml:"initial_peers"`
+}
+
+// TLS names the PEM files of mutual TLS between peers.
+type TLS struct {
+	CertFile string `json:"cert_file" yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file" toml:"key_file"`
+	CAFile   string `json:"ca_file" yaml:"ca_file" toml:"ca_file"`
+}
+
+// Duration is a time.Duration written as a string such as "2s" in a config
+// file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// defaultShutdownTimeout bounds the graceful shutdown of the HTTP server
+// unless ShutdownTimeout changes it.
+const defaultShutdownTimeout = 5 * time.Second
+
+// Load reads the config file at path, as YAML for .yaml and .yml, JSON
+// for .json and TOML for .toml, and checks it.
+func Load(path string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&c)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&c)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), &c)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", md.Undecoded())
+		}
+	default:
+		err = fmt.Errorf("unknown config format %q, want: .yaml, .yml, .json or .toml", ext)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := c.check(); err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+func (c Config) check() error {
+	if len(c.Peers) == 0 {
+		return errors.New("no peers")
+	}
+	if c.ID < 0 || c.ID >= len(c.Peers) {
+		return fmt.Errorf("id %d out of range of %d peers", c.ID, len(c.Peers))
+	}
+	switch c.Transport {
+	case "", "rpc", "grpc":
+	default:
+		return fmt.Errorf("unknown transport %q, want: rpc or grpc", c.Transport)
+	}
+	if c.TLS != nil && (c.TLS.CertFile == "" || c.TLS.KeyFile == "" || c.TLS.CAFile == "") {
+		return errors.New("tls needs cert_file, key_file and ca_file")
+	}
+	if c.AdminListen != "" && c.AdminListen == c.Listen {
+		return errors.New("admin_listen must differ from listen")
+	}
+	if c.BatchSize < 0 || c.Window < 0 || c.Alpha < 0 {
+		return errors.New("batch_size, window and alpha must not be negative")
+	}
+	if c.InitialPeers < 0 || c.InitialPeers > len(c.Peers) {
+		return fmt.Errorf("initial_peers %d out of range of %d peers", c.InitialPeers, len(c.Peers))
+	}
+	return nil
+}
+
+// Options returns the options of the peer c describes.
+func (c Config) Options() ([]gopaxos.Option, error) {
+	var opts []gopaxos.Option
+	var tlsConfig *tls.Config
+	if c.TLS != nil {
+		var err error
+		if tlsConfig, err = c.TLS.config(); err != nil {
+			return nil, err
+		}
+	}
+	if c.Transport == "grpc" {
+		var topts []grpctransport.Option
+		if tlsConfig != nil {
+			topts = append(topts, grpctransport.WithTLS(tlsConfig))
+		}
+		opts = append(opts, gopaxos.WithTransport(grpctransport.New(topts...)))
+	} else {
+		if tlsConfig != nil {
+			opts = append(opts, gopaxos.WithTLS(tlsConfig))
+		}
+		if c.IdleTimeout > 0 {
+			opts = append(opts, gopaxos.WithIdleTimeout(time.Duration(c.IdleTimeout)))
+		}
+	}
+	if c.RPCTimeout > 0 {
+		opts = append(opts, gopaxos.WithRPCTimeout(time.Duration(c.RPCTimeout)))
+	}
+	if c.ClusterID != "" {
+		opts = append(opts, gopaxos.WithClusterID(c.ClusterID))
+	}
+	if len(c.Learners) > 0 {
+		opts = append(opts, gopaxos.WithLearners(c.Learners...))
+	}
+	if c.Alpha > 0 {
+		opts = append(opts, gopaxos.WithAlpha(c.Alpha))
+	}
+	if c.InitialPeers > 0 {
+		opts = append(opts, gopaxos.WithInitialPeers(c.InitialPeers))
+	}
+	if c.Mencius {
+		opts = append(opts, gopaxos.WithMencius())
+	}
+	if c.Window > 0 {
+		opts = append(opts, gopaxos.WithWindow(c.Window))
+	}
+	if c.BatchSize > 0 {
+		opts = append(opts, gopaxos.WithBatching(c.BatchSize, time.Duration(c.BatchDelay)))
+	}
+	return opts, nil
+}
+
+// config loads the files of t.
+func (t *TLS) config() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificates", t.CAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
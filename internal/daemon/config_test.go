@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes data to a file called name in a temporary directory.
+func writeConfig(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFormats(t *testing.T) {
+	fmt.Println("Test: Config loads from YAML, JSON and TOML ...")
+
+	files := map[string]string{
+		"peer.yaml": `
+id: 1
+peers: [a:1/x, b:1/x, c:1/x]
+listen: 127.0.0.1:8080
+admin_listen: 127.0.0.1:8081
+data_dir: /var/lib/paxosd
+transport: grpc
+rpc_timeout: 2s
+shutdown_timeout: 1m
+cluster_id: prod
+batch_size: 16
+batch_delay: 5ms
+initial_peers: 2
+`,
+		"peer.json": `{
+	"id": 1,
+	"peers": ["a:1/x", "b:1/x", "c:1/x"],
+	"listen": "127.0.0.1:8080",
+	"admin_listen": "127.0.0.1:8081",
+	"data_dir": "/var/lib/paxosd",
+	"transport": "grpc",
+	"rpc_timeout": "2s",
+	"shutdown_timeout": "1m",
+	"cluster_id": "prod",
+	"batch_size": 16,
+	"batch_delay": "5ms",
+	"initial_peers": 2
+}`,
+		"peer.toml": `
+id = 1
+peers = ["a:1/x", "b:1/x", "c:1/x"]
+listen = "127.0.0.1:8080"
+admin_listen = "127.0.0.1:8081"
+data_dir = "/var/lib/paxosd"
+transport = "grpc"
+rpc_timeout = "2s"
+shutdown_timeout = "1m"
+cluster_id = "prod"
+batch_size = 16
+batch_delay = "5ms"
+initial_peers = 2
+`,
+	}
+	want := Config{
+		ID:              1,
+		Peers:           []string{"a:1/x", "b:1/x", "c:1/x"},
+		Listen:          "127.0.0.1:8080",
+		AdminListen:     "127.0.0.1:8081",
+		DataDir:         "/var/lib/paxosd",
+		Transport:       "grpc",
+		RPCTimeout:      Duration(2 * time.Second),
+		ShutdownTimeout: Duration(time.Minute),
+		ClusterID:       "prod",
+		BatchSize:       16,
+		BatchDelay:      Duration(5 * time.Millisecond),
+		InitialPeers:    2,
+	}
+	for name, data := range files {
+		c, err := Load(writeConfig(t, name, data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Fatalf("%s: got %+v, want %+v", name, c, want)
+		}
+		if _, err := c.Options(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestConfigErrors(t *testing.T) {
+	fmt.Println("Test: Config rejects invalid files ...")
+
+	for name, data := range map[string]string{
+		"unknown.yaml":   "id: 0\npeers: [a:1/x]\nadmin: :8080\n",
+		"unknown.json":   `{"id": 0, "peers": ["a:1/x"], "admin": ":8080"}`,
+		"unknown.toml":   "id = 0\npeers = [\"a:1/x\"]\nadmin = \":8080\"\n",
+		"id.yaml":        "id: 3\npeers: [a:1/x, b:1/x]\n",
+		"transport.yaml": "id: 0\npeers: [a:1/x]\ntransport: udp\n",
+		"tls.yaml":       "id: 0\npeers: [a:1/x]\ntls: {cert_file: peer.pem}\n",
+		"admin.yaml":     "id: 0\npeers: [a:1/x]\nlisten: :8080\nadmin_listen: :8080\n",
+		"duration.yaml":  "id: 0\npeers: [a:1/x]\nrpc_timeout: soon\n",
+		"initial.yaml":   "id: 0\npeers: [a:1/x]\ninitial_peers: 2\n",
+		"peer.ini":       "id=0\n",
+	} {
+		_, err := Load(writeConfig(t, name, data))
+		if err == nil {
+			t.Fatalf("%s: loaded", name)
+		}
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("%s: error %q does not name the file", name, err)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
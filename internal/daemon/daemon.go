@@ -0,0 +1,185 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos"
+)
+
+// snapshotFile is the name of the snapshot in Config.DataDir.
+const snapshotFile = "snapshot.gob"
+
+// Run runs the peer of c until ctx is done, then shuts it down gracefully:
+// the HTTP servers stop accepting requests and finish the ones in
+// progress, for up to ShutdownTimeout, the decided log is saved to DataDir,
+// and the peer is killed.
+//
+// With a DataDir, the acceptor state of the peer, its promises and votes,
+// is synced to DataDir before the peer answers a prepare or accept, and
+// restored on start, so a restarted peer keeps the promises it made. The
+// decided log is only saved on graceful shutdown; after a crash the peer
+// learns it again from the others. Without a DataDir nothing is kept, and
+// a peer that restarts may help decide a different value in an instance
+// it voted in before.
+func Run(ctx context.Context, c Config, log *slog.Logger) error {
+	opts, err := c.Options()
+	if err != nil {
+		return err
+	}
+	var lns []net.Listener
+	// fail releases the listeners if the peer does not get to serve on them.
+	fail := func(err error) error {
+		for _, ln := range lns {
+			ln.Close()
+		}
+		return err
+	}
+	var ln, adminLn net.Listener
+	if c.Listen != "" {
+		if ln, err = net.Listen("tcp", c.Listen); err != nil {
+			return err
+		}
+		lns = append(lns, ln)
+	}
+	if c.AdminListen != "" {
+		if adminLn, err = net.Listen("tcp", c.AdminListen); err != nil {
+			return fail(err)
+		}
+		lns = append(lns, adminLn)
+	}
+	if c.DataDir != "" {
+		if err := os.MkdirAll(c.DataDir, 0o755); err != nil {
+			return fail(err)
+		}
+		storage := newFileStorage(filepath.Join(c.DataDir, acceptorFile))
+		defer storage.Close()
+		opts = append(opts, gopaxos.WithStorage(storage))
+	}
+
+	px, err := gopaxos.New(c.Peers, c.ID, append(opts, gopaxos.WithLogger(log))...)
+	if err != nil {
+		return fail(err)
+	}
+	px.SetDebug(c.Debug)
+	if c.DataDir != "" {
+		n, err := restore(px, filepath.Join(c.DataDir, snapshotFile))
+		if err != nil {
+			px.Kill()
+			return fail(err)
+		}
+		log.Info("restored snapshot", "peer", c.ID, "instances", n)
+	}
+
+	errc := make(chan error, 2)
+	var srvs []*http.Server
+	serve := func(ln net.Listener, h http.Handler, what string) {
+		if ln == nil {
+			return
+		}
+		srv := &http.Server{Handler: h}
+		srvs = append(srvs, srv)
+		go func() { errc <- srv.Serve(ln) }()
+		log.Info("serving", "peer", c.ID, "what", what, "addr", ln.Addr().String())
+	}
+	serve(ln, Handler(px), "api")
+	serve(adminLn, AdminHandler(px), "admin")
+
+	select {
+	case <-ctx.Done():
+		err = nil
+	case err = <-errc:
+	}
+	log.Info("shutting down", "peer", c.ID)
+	timeout := time.Duration(c.ShutdownTimeout)
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), timeout)
+	for _, srv := range srvs {
+		if serr := srv.Shutdown(shutdown); serr != nil {
+			log.Warn("closing connections still open", "peer", c.ID, "err", serr)
+			srv.Close()
+		}
+	}
+	cancel()
+	if c.DataDir != "" {
+		if serr := save(px, filepath.Join(c.DataDir, snapshotFile)); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	px.Kill()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+// Handler serves the client API of px under /v1/ and its metrics on
+// /metrics.
+func Handler(px *gopaxos.Paxos) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", http.StripPrefix("/v1", apiHandler(px)))
+	mux.Handle("/metrics", px.MetricsHandler())
+	return mux
+}
+
+// AdminHandler serves the admin handler of px under /admin/. It has no
+// access control, so Run serves it on its own listener.
+func AdminHandler(px *gopaxos.Paxos) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", http.StripPrefix("/admin", px.AdminHandler()))
+	return mux
+}
+
+// restore installs the snapshot at path, if any, and returns the number of
+// decided instances px holds afterwards.
+func restore(px *gopaxos.Paxos, path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := px.InstallSnapshot(f); err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+	n := 0
+	for seq := px.Min(); seq <= px.Max(); seq++ {
+		if decided, _ := px.Status(seq); decided {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// save writes the decided log of px to path, replacing the file only once
+// the new snapshot is complete.
+func save(px *gopaxos.Paxos, path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := px.WriteSnapshot(f, px.Min()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yaoshengzhe/gopaxos"
+)
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// runPeer runs c in the background and waits for its HTTP server. The
+// returned function stops the peer and returns the error of Run.
+func runPeer(t *testing.T, c Config) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- Run(ctx, c, slog.New(slog.NewTextHandler(io.Discard, nil))) }()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if resp, err := http.Get("http://" + c.Listen + "/metrics"); err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			cancel()
+			t.Fatalf("peer %s not serving: %v", c.Listen, <-errc)
+		}
+	}
+	return func() error {
+		http.DefaultClient.CloseIdleConnections()
+		cancel()
+		return <-errc
+	}
+}
+
+// call sends a request to the peer listening on addr and decodes the JSON
+// answer into v.
+func call(t *testing.T, method, addr, path string, body, v any) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://"+addr+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: %s: %s", method, path, resp.Status, msg)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+}
+
+func TestDaemonAPI(t *testing.T) {
+	fmt.Println("Test: The daemon proposes and reads the log ...")
+
+	c := Config{
+		Peers:       []string{freeAddr(t) + "/paxosd"},
+		Listen:      freeAddr(t),
+		AdminListen: freeAddr(t),
+		DataDir:     filepath.Join(t.TempDir(), "data"),
+	}
+	stop := runPeer(t, c)
+
+	var p proposed
+	call(t, "POST", c.Listen, "/v1/propose", proposal{Value: "x"}, &p)
+	var e entry
+	call(t, "GET", c.Listen, fmt.Sprintf("/v1/log/%d?wait=5s", p.Seq), nil, &e)
+	if !e.Decided {
+		t.Fatalf("seq %d not decided", p.Seq)
+	}
+	call(t, "GET", c.Listen, "/v1/log/100?wait=10ms", nil, &e)
+	if e.Decided {
+		t.Fatalf("seq 100 decided")
+	}
+	var entries []entry
+	call(t, "GET", c.Listen, "/v1/log", nil, &entries)
+	if len(entries) != 1 || entries[0].Seq != p.Seq {
+		t.Fatalf("log %+v", entries)
+	}
+
+	for _, query := range []string{"from=0&to=100000", "from=2&to=1"} {
+		resp, err := http.Get("http://" + c.Listen + "/v1/log?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("range %s answered %s", query, resp.Status)
+		}
+	}
+
+	// the admin handler is only served on its own listener.
+	for addr, want := range map[string]int{c.Listen: http.StatusNotFound, c.AdminListen: http.StatusOK} {
+		resp, err := http.Get("http://" + addr + "/admin/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("/admin/status on %s answered %s, want %d", addr, resp.Status, want)
+		}
+	}
+
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestDaemonConsistentRead(t *testing.T) {
+	fmt.Println("Test: A consistent read leaves later proposals intact ...")
+
+	px, err := gopaxos.New([]string{freeAddr(t) + "/paxosd"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer px.Kill()
+	srv := httptest.NewServer(Handler(px))
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	// seq 1 was never used.
+	px.Start(0, "a")
+	px.Start(2, "b")
+	var e entry
+	call(t, "GET", addr, "/v1/log/2?wait=5s", nil, &e)
+	if !e.Decided {
+		t.Fatalf("seq 2 not decided")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr+"/v1/log/2?consistent=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatalf("consistent read over a hole answered %s", resp.Status)
+	}
+
+	px.Start(1, "later")
+	call(t, "GET", addr, "/v1/log/1?wait=5s&consistent=true", nil, &e)
+	if !e.Decided || e.Value != "later" {
+		t.Fatalf("seq 1 = %+v, want: decided later", e)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestDaemonRestart(t *testing.T) {
+	fmt.Println("Test: The daemon keeps its log and votes across restarts ...")
+
+	c := Config{
+		Peers:   []string{freeAddr(t) + "/paxosd"},
+		Listen:  freeAddr(t),
+		DataDir: t.TempDir(),
+	}
+	stop := runPeer(t, c)
+	var p proposed
+	call(t, "POST", c.Listen, "/v1/propose", proposal{Value: "kept"}, &p)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(c.DataDir, snapshotFile)); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(c.DataDir, acceptorFile)); err != nil || fi.Size() == 0 {
+		t.Fatalf("acceptor state: %v, %v, want: the vote of seq %d", fi, err, p.Seq)
+	}
+
+	stop = runPeer(t, c)
+	defer stop()
+	var e entry
+	call(t, "GET", c.Listen, fmt.Sprintf("/v1/log/%d", p.Seq), nil, &e)
+	if !e.Decided {
+		t.Fatalf("seq %d lost on restart", p.Seq)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestDaemonPeerAddrInUse(t *testing.T) {
+	fmt.Println("Test: The daemon reports a peer address in use ...")
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	c := Config{
+		Peers:       []string{busy.Addr().String() + "/paxosd"},
+		Listen:      freeAddr(t),
+		AdminListen: freeAddr(t),
+	}
+	if err := Run(context.Background(), c, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Fatalf("Run() with peer address %s in use = nil, want: an error", busy.Addr())
+	}
+	// the HTTP listeners were released.
+	for _, addr := range []string{c.Listen, c.AdminListen} {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatalf("listen address %s not released: %v", addr, err)
+		}
+		l.Close()
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/yaoshengzhe/gopaxos"
+)
+
+// acceptorFile is the name of the acceptor state in Config.DataDir.
+const acceptorFile = "acceptors.log"
+
+// fileStorage is a gopaxos.Storage that appends every state to a file and
+// syncs it before Save returns. Each record is a 4-byte big-endian length
+// followed by the gob of one gopaxos.AcceptorState. A record cut short by
+// a crash was never acknowledged, so Load drops it. Load and Forget
+// rewrite the file with only the latest state of each instance the peer
+// has not forgotten; between rewrites it also holds the earlier states
+// Save appended.
+type fileStorage struct {
+	path   string
+	f      *os.File
+	latest map[int]gopaxos.AcceptorState // by seq, as in the file
+}
+
+func newFileStorage(path string) *fileStorage {
+	return &fileStorage{path: path}
+}
+
+// Load reads the saved states, compacts the file and opens it for Save.
+func (s *fileStorage) Load() ([]gopaxos.AcceptorState, error) {
+	s.latest = make(map[int]gopaxos.AcceptorState)
+	f, err := os.Open(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		r := bufio.NewReader(f)
+		for {
+			st, err := readState(r)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %v", s.path, err)
+			}
+			s.latest[st.Seq] = st
+		}
+		f.Close()
+	}
+	if err := s.rewrite(); err != nil {
+		return nil, err
+	}
+	return s.states(), nil
+}
+
+// Save appends st to the file and syncs it.
+func (s *fileStorage) Save(st gopaxos.AcceptorState) error {
+	if s.f == nil {
+		return errors.New("acceptor state not loaded")
+	}
+	if err := writeState(s.f, st); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.latest[st.Seq] = st
+	return nil
+}
+
+// Forget drops the states of the instances below seq and rewrites the file
+// without them.
+func (s *fileStorage) Forget(seq int) error {
+	if s.f == nil {
+		return errors.New("acceptor state not loaded")
+	}
+	forgot := false
+	for q := range s.latest {
+		if q < seq {
+			delete(s.latest, q)
+			forgot = true
+		}
+	}
+	if !forgot {
+		return nil
+	}
+	return s.rewrite()
+}
+
+// states returns the latest state of each instance in seq order.
+func (s *fileStorage) states() []gopaxos.AcceptorState {
+	states := make([]gopaxos.AcceptorState, 0, len(s.latest))
+	for _, st := range s.latest {
+		states = append(states, st)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Seq < states[j].Seq })
+	return states
+}
+
+// rewrite replaces the file with one that holds s.latest, and opens it for
+// Save.
+func (s *fileStorage) rewrite() error {
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	for _, st := range s.states() {
+		if err := writeState(f, st); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		f.Close()
+		return err
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f = f
+	return nil
+}
+
+// Close closes the file.
+func (s *fileStorage) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+func writeState(w io.Writer, st gopaxos.AcceptorState) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(st); err != nil {
+		return err
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err := w.Write(b)
+	return err
+}
+
+func readState(r io.Reader) (gopaxos.AcceptorState, error) {
+	var st gopaxos.AcceptorState
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return st, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(n[:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return st, err
+	}
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&st)
+	return st, err
+}
@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yaoshengzhe/gopaxos"
+)
+
+func TestFileStorage(t *testing.T) {
+	fmt.Println("Test: Acceptor state survives a restart of the file ...")
+
+	path := filepath.Join(t.TempDir(), acceptorFile)
+	s := newFileStorage(path)
+	if states, err := s.Load(); err != nil || len(states) != 0 {
+		t.Fatalf("Load() of a new file = %v, %v, want: nothing", states, err)
+	}
+	for _, st := range []gopaxos.AcceptorState{
+		{Seq: 0, NP: 1, NA: -1},
+		{Seq: 0, NP: 1, NA: 1, VA: "x"},
+		{Seq: 3, NP: 0, NA: 0, VA: gopaxos.Batch{From: 1, Values: []gopaxos.Value{"y"}}},
+	} {
+		if err := s.Save(st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	// a crash cut the last record short.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1})
+	f.Close()
+
+	s = newFileStorage(path)
+	defer func() { s.Close() }()
+	states, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].Seq != 0 || states[0].VA != "x" || states[1].Seq != 3 {
+		t.Fatalf("Load() = %+v, want: the latest state of instances 0 and 3", states)
+	}
+	if err := s.Save(gopaxos.AcceptorState{Seq: 4, NP: 2, NA: -1}); err != nil {
+		t.Fatalf("Save() after Load() of a cut file: %v", err)
+	}
+
+	// the peer forgot instance 0.
+	if err := s.Forget(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(gopaxos.AcceptorState{Seq: 4, NP: 3, NA: -1}); err != nil {
+		t.Fatalf("Save() after Forget(): %v", err)
+	}
+	s.Close()
+	s = newFileStorage(path)
+	if states, err = s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].Seq != 3 || states[1].Seq != 4 || states[1].NP != 3 {
+		t.Fatalf("Load() after Forget(1) = %+v, want: the latest state of instances 3 and 4", states)
+	}
+
+	fmt.Println("  ... Passed")
+}
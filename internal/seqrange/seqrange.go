@@ -0,0 +1,41 @@
+// Package seqrange parses the ?from=&to= instance range of the HTTP
+// handlers that list decided instances.
+package seqrange
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// FromQuery returns the bounds of the ?from=&to= query of r. A missing bound
+// is clamped to [lo, hi] so that the range covers at most limit instances,
+// the last ones up to hi if both are missing. A range of more than limit
+// instances is an error.
+func FromQuery(r *http.Request, lo, hi, limit int) (int, int, error) {
+	var bounds [2]int
+	var given [2]bool
+	for i, name := range []string{"from", "to"} {
+		if s := r.URL.Query().Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid %s: %v", name, err)
+			}
+			bounds[i], given[i] = n, true
+		}
+	}
+	from, to := bounds[0], bounds[1]
+	switch {
+	case !given[0] && !given[1]:
+		to, from = hi, max(lo, hi-limit+1)
+	case !given[0]:
+		from = max(lo, to-limit+1)
+	case !given[1]:
+		to = min(hi, from+limit-1)
+	case to < from:
+		return 0, 0, fmt.Errorf("invalid range, to %d is below from %d", to, from)
+	case to-from >= limit:
+		return 0, 0, fmt.Errorf("range too large, want: at most %d instances", limit)
+	}
+	return from, to, nil
+}
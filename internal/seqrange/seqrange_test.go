@@ -0,0 +1,31 @@
+package seqrange
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromQuery(t *testing.T) {
+	fmt.Println("Test: Ranges clamp missing bounds ...")
+
+	for query, want := range map[string][2]int{
+		"":                 {5000 - 1000 + 1, 5000},
+		"?from=10":         {10, 10 + 1000 - 1},
+		"?to=20":           {3, 20},
+		"?from=4990":       {4990, 5000},
+		"?from=10&to=1009": {10, 1009},
+	} {
+		from, to, err := FromQuery(httptest.NewRequest("GET", "/log"+query, nil), 3, 5000, 1000)
+		if err != nil || from != want[0] || to != want[1] {
+			t.Fatalf("%q: [%d, %d], %v, want: %v", query, from, to, err, want)
+		}
+	}
+	for _, query := range []string{"?from=x", "?from=2&to=1", "?from=0&to=1000"} {
+		if _, _, err := FromQuery(httptest.NewRequest("GET", "/log"+query, nil), 3, 5000, 1000); err == nil {
+			t.Fatalf("%q: no error", query)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,202 @@
+package gopaxos
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// A leader lease lets one peer serve reads from its local state without
+// running a Paxos round. A voter that grants a lease promises not to take
+// part in any other peer's prepare or accept until the lease expires on its
+// own clock. Once a prepare quorum has granted the lease, no other proposer
+// can complete a round, so every decision goes through the holder.
+//
+// The holder starts its lease clock before it asks for grants, and a voter
+// starts its clock only when the request arrives. The holder also shortens
+// its lease by the allowed clock drift. As long as no clock runs faster
+// than that, the holder stops reading locally before any voter lets its
+// grant lapse.
+//
+// Instances decided before the grant went through an accept quorum, which
+// shares a voter with the granting quorum. Each grant carries the highest
+// instance its voter voted in, and the holder learns every instance up to
+// the highest of them before it takes the lease.
+//
+// A holder can hand leadership over early with ReleaseLease: it stops
+// reading locally, then asks the voters to drop its grant, so another peer
+// can acquire the lease without waiting for it to expire. Requests carry
+// the number of leases the holder released before in Round, so a release
+// that arrives late never drops a grant made after it.
+
+// ErrNoLease is returned by LeaseStatus when this peer does not hold a valid
+// lease.
+var ErrNoLease = errors.New("gopaxos: no valid leader lease")
+
+// ErrNoQuorum is returned when too few voters answered to form a quorum.
+var ErrNoQuorum = errors.New("gopaxos: no quorum of voters answered")
+
+// Clock tells time. Tests substitute a fake one to control lease expiry.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// WithClock replaces the wall clock used for leases.
+func WithClock(c Clock) Option {
+	return func(p *Paxos) {
+		p.clock = c
+	}
+}
+
+// WithLease enables leader leases of duration d. drift bounds the relative
+// rate at which any two clocks in the cluster may diverge, e.g. 0.01 for 1%.
+func WithLease(d time.Duration, drift float64) Option {
+	if d <= 0 || drift < 0 || drift >= 1 {
+		panic("invalid lease, want: d > 0 and 0 <= drift < 1")
+	}
+	return func(p *Paxos) {
+		p.lease.duration = d
+		p.lease.drift = drift
+	}
+}
+
+type leaseState struct {
+	duration time.Duration
+	drift    float64
+
+	// as a holder
+	expiry   time.Time
+	released int // leases given up with ReleaseLease
+
+	// as a voter
+	holder     int
+	holderTill time.Time
+	holderNum  int // released of the holder when it was granted
+}
+
+// AcquireLease asks the voters to grant this peer a lease, or to extend the
+// one it holds, and reports whether it now holds one. It fails if this peer
+// cannot learn the instances decided before the grant within a lease
+// duration. A holder renews it by calling AcquireLease again, e.g. every
+// half lease duration. It only waits for those instances, see WaitApplied,
+// so an instance below the grant that nobody proposes in makes it fail.
+func (p *Paxos) AcquireLease() bool {
+	p.mu.Lock()
+	if p.lease.duration == 0 {
+		p.mu.Unlock()
+		return false
+	}
+	start := p.clock.Now()
+	config, seq, num := p.currentConfig(), p.logger.next, p.lease.released
+	p.mu.Unlock()
+
+	replies := p.callVoters(p.ctx, config, "Handler.OnReceiveLeaseRequest", &Request{FromID: p.ID(), Seq: seq, Round: num})
+	if !config.IsQuorum(PhasePrepare, acked(replies)) {
+		return false
+	}
+	voted := -1
+	for _, resp := range replies {
+		if resp.OK && resp.Seq > voted {
+			voted = resp.Seq
+		}
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, p.lease.duration)
+	defer cancel()
+	if p.WaitApplied(ctx, voted) != nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lease.released != num {
+		return false
+	}
+	safe := time.Duration(float64(p.lease.duration) * (1 - p.lease.drift))
+	if expiry := start.Add(safe); expiry.After(p.lease.expiry) {
+		p.lease.expiry = expiry
+	}
+	return p.clock.Now().Before(p.lease.expiry)
+}
+
+// ReleaseLease gives up the lease this peer holds, if any, so that another
+// peer can acquire one at once instead of after the lease expires. This
+// peer stops reading locally first. It reports whether a prepare quorum of
+// voters dropped the grant; a voter that missed the release keeps it until
+// it expires.
+func (p *Paxos) ReleaseLease() bool {
+	p.mu.Lock()
+	if p.lease.duration == 0 {
+		p.mu.Unlock()
+		return false
+	}
+	p.lease.expiry = time.Time{}
+	num := p.lease.released
+	p.lease.released++
+	config, seq := p.currentConfig(), p.logger.next
+	p.mu.Unlock()
+
+	replies := p.callVoters(p.ctx, config, "Handler.OnReceiveLeaseRelease", &Request{FromID: p.ID(), Seq: seq, Round: num})
+	return config.IsQuorum(PhasePrepare, acked(replies))
+}
+
+// HasLease reports whether this peer holds a valid lease.
+func (p *Paxos) HasLease() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.clock.Now().Before(p.lease.expiry)
+}
+
+// LeaseStatus is Status for linearizable reads: it fails with ErrNoLease
+// unless this peer holds a lease, in which case no other peer can have
+// decided an instance this peer has not learned of through its own rounds.
+func (p *Paxos) LeaseStatus(seq int) (bool, Value, error) {
+	if !p.HasLease() {
+		return false, nil, ErrNoLease
+	}
+	decided, v := p.Status(seq)
+	return decided, v, nil
+}
+
+// leaseBlocks reports whether this voter granted a lease that forbids it to
+// take part in a round of peer from. p.mu must be held.
+func (p *Paxos) leaseBlocks(from int) bool {
+	return p.lease.holder != from && p.clock.Now().Before(p.lease.holderTill)
+}
+
+// OnReceiveLeaseRequest grants a lease to req.FromID unless another peer
+// holds one, and reports the highest instance this voter voted in.
+func (h *Handler) OnReceiveLeaseRequest(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	l := &h.pxs.lease
+	if l.duration == 0 || h.pxs.leaseBlocks(req.FromID) {
+		return nil
+	}
+	l.holder, l.holderTill, l.holderNum = req.FromID, h.pxs.clock.Now().Add(l.duration), req.Round
+	response.OK = true
+	response.Seq = h.pxs.highestVoted()
+	return nil
+}
+
+// OnReceiveLeaseRelease drops the grant of req.FromID's lease, unless it was
+// granted after the release was sent.
+func (h *Handler) OnReceiveLeaseRelease(req *Request, response *Response) error {
+	if err := h.pxs.checkVoter(req); err != nil {
+		return err
+	}
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	l := &h.pxs.lease
+	if l.holder == req.FromID && l.holderNum <= req.Round {
+		l.holderTill = time.Time{}
+	}
+	response.OK = true
+	return nil
+}
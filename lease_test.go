@@ -0,0 +1,239 @@
+package gopaxos
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestLeaseVoterGrantsOneHolder(t *testing.T) {
+	fmt.Println("Test: Voter grants one lease at a time ...")
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pxs := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 2, WithClock(clock), WithLease(10*time.Second, 0.1))
+	h := NewHandler(pxs)
+
+	var resp Response
+	h.OnReceiveLeaseRequest(&Request{FromID: 0}, &resp)
+	if !resp.OK {
+		t.Fatalf("lease to peer 0 not granted")
+	}
+	resp = Response{}
+	h.OnReceiveLeaseRequest(&Request{FromID: 1}, &resp)
+	if resp.OK {
+		t.Fatalf("lease to peer 1 granted while peer 0 holds one")
+	}
+	if err := h.OnReceiveFastAccept(&Request{FromID: 1, Round: 1, Value: "x"}, &Response{}); err == nil {
+		t.Fatalf("voter took part in peer 1's round during peer 0's lease")
+	}
+	if err := h.OnReceiveFastAccept(&Request{FromID: 0, Round: 1, Value: "x"}, &Response{}); err != nil {
+		t.Fatalf("voter rejected the lease holder: %v", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	resp = Response{}
+	h.OnReceiveLeaseRequest(&Request{FromID: 1}, &resp)
+	if !resp.OK {
+		t.Fatalf("lease to peer 1 not granted after peer 0's expired")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestLeaseVoterDropsReleasedGrant(t *testing.T) {
+	fmt.Println("Test: Voter drops a released grant, not a later one ...")
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pxs := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 2, WithClock(clock), WithLease(10*time.Second, 0.1))
+	h := NewHandler(pxs)
+
+	var resp Response
+	h.OnReceiveLeaseRequest(&Request{FromID: 0, Round: 0}, &resp)
+	if !resp.OK {
+		t.Fatalf("lease to peer 0 not granted")
+	}
+	if h.OnReceiveLeaseRelease(&Request{FromID: 0, Round: 0}, &Response{}); pxs.leaseBlocks(1) {
+		t.Fatalf("voter still blocks peer 1 after peer 0 released its lease")
+	}
+
+	resp = Response{}
+	h.OnReceiveLeaseRequest(&Request{FromID: 0, Round: 1}, &resp)
+	if !resp.OK {
+		t.Fatalf("second lease to peer 0 not granted")
+	}
+	// the release of the first lease arrives late.
+	if h.OnReceiveLeaseRelease(&Request{FromID: 0, Round: 0}, &Response{}); !pxs.leaseBlocks(1) {
+		t.Fatalf("a late release dropped the second lease of peer 0")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLeaseReads(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("lease-reads", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithClock(clock), WithLease(10*time.Second, 0.1))
+	}
+
+	fmt.Println("Test: Leader lease reads ...")
+
+	if _, _, err := pxa[0].LeaseStatus(0); err != ErrNoLease {
+		t.Fatalf("LeaseStatus() without a lease = %v, want: %v", err, ErrNoLease)
+	}
+	if !pxa[0].AcquireLease() {
+		t.Fatalf("peer 0 did not get a lease")
+	}
+	if pxa[1].AcquireLease() {
+		t.Fatalf("peer 1 got a lease while peer 0 holds one")
+	}
+
+	pxa[0].StartFast(0, "hello")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if decided, v, err := pxa[0].LeaseStatus(0); err != nil || !decided || v != "hello" {
+		t.Fatalf("LeaseStatus(0) = %v, %v, %v, want: true, hello, nil", decided, v, err)
+	}
+
+	// the holder gives up 10% early to cover clock drift.
+	clock.Advance(9 * time.Second)
+	if pxa[0].HasLease() {
+		t.Fatalf("peer 0 still holds its lease past the drift bound")
+	}
+	clock.Advance(time.Second)
+	if !pxa[1].AcquireLease() {
+		t.Fatalf("peer 1 did not get a lease after peer 0's expired")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLeaseCatchesUp(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	pxa, pxh, fts := makeFiltered("lease-catches-up", 3, WithClock(clock), WithLease(10*time.Second, 0.1))
+	defer cleanup(pxa)
+
+	fmt.Println("Test: Lease holder learns instances decided before the grant ...")
+
+	isolate(pxh, fts, []int{0}, []int{1, 2})
+	pxa[1].Start(0, "before")
+	if err := waitN(pxa[1:], 0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	isolate(pxh, fts, nil, nil)
+	if !pxa[0].AcquireLease() {
+		t.Fatalf("peer 0 did not get a lease")
+	}
+	if decided, v, err := pxa[0].LeaseStatus(0); err != nil || !decided || v != "before" {
+		t.Fatalf("LeaseStatus(0) = %v, %v, %v, want: true, before, nil", decided, v, err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLeaseLeavesHoles(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("lease-holes", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithClock(clock), WithLease(time.Second, 0.1))
+	}
+
+	fmt.Println("Test: Lease holder waits for holes instead of filling them ...")
+
+	// seq 1 was never used.
+	pxa[0].Start(0, "a")
+	pxa[0].Start(2, "b")
+	if err := waitN(pxa, 2, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	if pxa[2].AcquireLease() {
+		t.Fatalf("peer 2 got a lease without learning instance 1")
+	}
+	if n, err := ndecided(pxa, 1); n != 0 || err != nil {
+		t.Fatalf("lease decided hole 1: %v", err)
+	}
+
+	pxa[2].Start(1, 300)
+	if err := waitN(pxa, 1, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	if !pxa[2].AcquireLease() {
+		t.Fatalf("peer 2 did not get a lease")
+	}
+	if decided, v, err := pxa[2].LeaseStatus(1); err != nil || !decided || v != 300 {
+		t.Fatalf("LeaseStatus(1) = %v, %v, %v, want: true, 300, nil", decided, v, err)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLeaseTransfer(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("lease-transfer", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithClock(clock), WithLease(10*time.Second, 0.1))
+	}
+
+	fmt.Println("Test: Lease holder hands the lease over before it expires ...")
+
+	if !pxa[0].AcquireLease() {
+		t.Fatalf("peer 0 did not get a lease")
+	}
+	if !pxa[0].ReleaseLease() {
+		t.Fatalf("peer 0 could not release its lease")
+	}
+	if pxa[0].HasLease() {
+		t.Fatalf("peer 0 still holds the lease it released")
+	}
+	if !pxa[1].AcquireLease() {
+		t.Fatalf("peer 1 did not get a lease after peer 0 released its own")
+	}
+	if pxa[0].AcquireLease() {
+		t.Fatalf("peer 0 got a lease back while peer 1 holds one")
+	}
+
+	fmt.Println("  ... Passed")
+}
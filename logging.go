@@ -0,0 +1,64 @@
+package gopaxos
+
+import (
+	"log/slog"
+	"os"
+)
+
+// A peer logs through a Logger set with WithLogger. Every record carries
+// the peer's ID as "peer", and records about an instance carry "seq" and,
+// in a round-based protocol, "round" (the ballot). Protocol traces, one
+// per received request, decision and classic round, are logged at debug
+// level and only while SetDebug is on, so they can be switched on in a
+// running process without restarting it.
+
+// Logger is the leveled, structured logger of a peer. args are alternating
+// keys and values as in log/slog; *slog.Logger implements Logger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// WithLogger makes the peer log through l. Without it the peer logs text
+// to stderr. Protocol traces reach l at debug level, so a *slog.Logger
+// whose handler drops debug records never shows them.
+func WithLogger(l Logger) Option {
+	if l == nil {
+		panic("invalid logger, want: not nil")
+	}
+	return func(p *Paxos) {
+		p.log = l
+	}
+}
+
+// NewSlogLogger returns a Logger writing to h.
+func NewSlogLogger(h slog.Handler) Logger {
+	return slog.New(h)
+}
+
+func defaultLogger() Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// SetDebug turns protocol traces on or off. It is safe to call at any
+// time.
+func (p *Paxos) SetDebug(on bool) {
+	p.debug.Store(on)
+}
+
+// logDebug logs a protocol trace if SetDebug is on.
+func (p *Paxos) logDebug(msg string, args ...any) {
+	if p.debug.Load() {
+		p.log.Debug(msg, p.logFields(args)...)
+	}
+}
+
+func (p *Paxos) logWarn(msg string, args ...any) {
+	p.log.Warn(msg, p.logFields(args)...)
+}
+
+func (p *Paxos) logFields(args []any) []any {
+	return append([]any{"peer", p.id}, args...)
+}
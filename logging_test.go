@@ -0,0 +1,57 @@
+package gopaxos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggerDebugSwitch(t *testing.T) {
+	fmt.Println("Test: Protocol traces follow SetDebug ...")
+
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	peers := []string{"a:1/x", "b:1/x", "c:1/x"}
+	p := newPaxos(peers, 1, WithLogger(l))
+	h := NewHandler(p)
+	req := &Request{FromID: 0, Seq: 7, Round: 4, ClusterID: p.ClusterID()}
+
+	if err := h.Admit("Handler.OnReceiveFastPrepare", req); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("traced with debug off: %s", buf.String())
+	}
+
+	p.SetDebug(true)
+	if err := h.Admit("Handler.OnReceiveFastPrepare", req); err != nil {
+		t.Fatal(err)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	for key, want := range map[string]any{
+		"level": "DEBUG", "peer": 1.0, "from": 0.0, "seq": 7.0, "round": 4.0,
+		"method": "Handler.OnReceiveFastPrepare",
+	} {
+		if rec[key] != want {
+			t.Fatalf("%s = %v, want: %v in %s", key, rec[key], want, buf.String())
+		}
+	}
+
+	p.SetDebug(false)
+	buf.Reset()
+	req.ClusterID = "other"
+	if err := h.Admit("Handler.OnReceiveFastPrepare", req); err == nil {
+		t.Fatal("admitted a request of another cluster")
+	}
+	if out := buf.String(); !strings.Contains(out, `"level":"WARN"`) || !strings.Contains(out, `"cluster":"other"`) {
+		t.Fatalf("rejection not logged as a warning: %s", out)
+	}
+
+	fmt.Println("  ... Passed")
+}
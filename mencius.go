@@ -0,0 +1,177 @@
+package gopaxos
+
+import (
+	"context"
+	"encoding/gob"
+	"time"
+)
+
+// Mencius rotates the leader role over the instances: voter i of a config
+// owns every instance seq with seq mod len(Voters()) == i and is its default
+// leader. The owner skips the prepare phase, since round 0 of an owned
+// instance is reserved for it, and only needs one accept round.
+//
+// An owner that falls behind the others decides Skip in the instances it
+// did not use, so the log has no holes. A Start on another peer is
+// forwarded to the owner. If the owner does not decide the instance in
+// time, the caller revokes it with classic rounds.
+//
+// Mencius instances share the round-based acceptor state of StartFast. Only
+// the owner sends round 0, so the first value an acceptor votes for in
+// round 0 is always the owner's.
+
+// Skip is decided in the instances an idle Mencius owner gives up, and in
+// holes filled by WaitApplied.
+type Skip struct{}
+
+// menciusRevokeTimeout is how long a forwarded Start waits for the owner.
+const menciusRevokeTimeout = time.Second
+
+type menciusState struct {
+	proposed map[int]bool // owned instances this peer has proposed in
+	skipped  int          // owned instances below this are proposed or skipped
+}
+
+// WithMencius makes every Start run in Mencius mode. All peers of a
+// cluster must enable it.
+func WithMencius() Option {
+	return func(p *Paxos) {
+		p.mencius = &menciusState{proposed: make(map[int]bool)}
+	}
+}
+
+// Owner returns the id of the voter that leads instance seq in Mencius mode.
+func (c Config) Owner(seq int) int {
+	voters := c.Voters()
+	return voters[seq%len(voters)]
+}
+
+func (p *Paxos) startMencius(ctx context.Context, config Config, seq int, v Value) {
+	owner := config.Owner(seq)
+	if owner == p.ID() {
+		p.proposeOwned(ctx, config, seq, v)
+		return
+	}
+
+	p.callPeers(ctx, config, []int{owner}, "Handler.OnReceiveMenciusForward",
+		&Request{FromID: p.ID(), Seq: seq, Value: v})
+	for start := time.Now(); time.Since(start) < menciusRevokeTimeout; {
+		if decided, _ := p.Status(seq); decided {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	p.revoke(ctx, config, seq, 0, v)
+}
+
+// proposeOwned proposes v in round 0 of seq, which this peer owns, after
+// skipping the owned instances below seq it never used.
+func (p *Paxos) proposeOwned(ctx context.Context, config Config, seq int, v Value) {
+	p.mu.Lock()
+	if p.mencius.proposed[seq] {
+		p.mu.Unlock()
+		return
+	}
+	p.mencius.proposed[seq] = true
+	p.mu.Unlock()
+
+	p.skipBelow(ctx, config, seq)
+
+	rctx, span := p.startRound(ctx, seq, fastRound)
+	replies := p.callVoters(rctx, config, "Handler.OnReceiveFastAccept",
+		&Request{FromID: p.ID(), Seq: seq, Round: fastRound, Value: v})
+	span.End()
+	if config.IsQuorum(PhaseAccept, acked(replies)) {
+		p.metrics.rounds.observe(1)
+		p.broadcastDecision(ctx, config, seq, v)
+		return
+	}
+	p.revoke(ctx, config, seq, 1, v)
+}
+
+// skipBelow decides Skip in every owned instance below seq that this peer
+// has not proposed in.
+func (p *Paxos) skipBelow(ctx context.Context, config Config, seq int) {
+	p.mu.Lock()
+	var skip []int
+	for s := p.mencius.skipped; s < seq; s++ {
+		if config.Owner(s) == p.ID() && !p.mencius.proposed[s] {
+			p.mencius.proposed[s] = true
+			skip = append(skip, s)
+		}
+	}
+	if seq > p.mencius.skipped {
+		p.mencius.skipped = seq
+	}
+	p.mu.Unlock()
+
+	if len(skip) == 0 {
+		return
+	}
+	// the caller may hold a window slot itself, so wait for slots apart.
+	go func() {
+		for _, s := range skip {
+			p.proposeFrom(ctx, s, func(ctx context.Context) { p.skip(ctx, config, s) })
+		}
+	}()
+}
+
+// skip proposes Skip in round 0 of seq, which this peer owns.
+func (p *Paxos) skip(ctx context.Context, config Config, seq int) {
+	rctx, span := p.startRound(ctx, seq, fastRound)
+	replies := p.callVoters(rctx, config, "Handler.OnReceiveFastAccept",
+		&Request{FromID: p.ID(), Seq: seq, Round: fastRound, Value: Skip{}})
+	span.End()
+	if config.IsQuorum(PhaseAccept, acked(replies)) {
+		p.metrics.rounds.observe(1)
+		p.broadcastDecision(ctx, config, seq, Skip{})
+		return
+	}
+	p.revoke(ctx, config, seq, 1, Skip{})
+}
+
+// revoke takes over instance seq from its owner with classic rounds,
+// proposing v unless the owner's value may already have been chosen. prior
+// is the number of rounds this peer already ran in seq.
+func (p *Paxos) revoke(ctx context.Context, config Config, seq, prior int, v Value) {
+	pick := func(promises []Response) Value {
+		return pickClassicValue(promises, v)
+	}
+	p.runClassicRounds(ctx, config, seq, prior, pick, config.IsQuorum)
+}
+
+// OnReceiveMenciusForward asks the owner of req.Seq to propose req.Value.
+// An owner that already used the instance ignores it.
+func (h *Handler) OnReceiveMenciusForward(req *Request, response *Response) error {
+	h.pxs.mu.Lock()
+	config, ok := h.pxs.configFor(req.Seq)
+	h.pxs.mu.Unlock()
+	if ok && h.pxs.mencius != nil && config.Owner(req.Seq) == h.pxs.ID() {
+		// proposeFrom may wait for a window slot, which a handler must not.
+		go h.pxs.proposeFrom(h.pxs.remoteContext(req), req.Seq, func(ctx context.Context) {
+			h.pxs.proposeOwned(ctx, config, req.Seq, req.Value)
+		})
+		response.OK = true
+	}
+	return nil
+}
+
+// menciusHeard lets an owner that saw another owner propose in seq skip its
+// own unused instances below seq.
+func (p *Paxos) menciusHeard(seq int) {
+	p.mu.Lock()
+	config, ok := p.configFor(seq)
+	behind := ok && p.mencius.skipped < seq
+	p.mu.Unlock()
+	if behind {
+		p.skipBelow(p.ctx, config, seq)
+	}
+}
+
+func init() {
+	gob.Register(Skip{})
+}
@@ -0,0 +1,123 @@
+package gopaxos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMenciusOwners(t *testing.T) {
+	fmt.Println("Test: Mencius owners rotate over voters ...")
+
+	// peer 1 is a learner, so voters 0, 2 and 3 take turns.
+	c := Config{Peers: []string{"a:1/p", "b:1/p", "c:1/p", "d:1/p"}, Learners: []int{1}}
+	for seq, want := range []int{0, 2, 3, 0, 2, 3} {
+		if got := c.Owner(seq); got != want {
+			t.Fatalf("Owner(%d) = %d, want: %d", seq, got, want)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosMenciusSkipsIdleOwners(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("mencius-skips-idle-owners", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithMencius())
+	}
+
+	fmt.Println("Test: Mencius, idle owners skip their turns ...")
+
+	pxa[0].Start(9, "nine")
+	for seq := 0; seq <= 9; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+		_, v := pxa[0].Status(seq)
+		if seq == 9 && v != "nine" {
+			t.Fatalf("Status(9) = %v, want: nine", v)
+		}
+		if seq < 9 && v != (Skip{}) {
+			t.Fatalf("Status(%d) = %v, want: Skip{}", seq, v)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosMenciusWindow(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("mencius-window", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithMencius(), WithWindow(1))
+	}
+
+	fmt.Println("Test: Mencius, skips and forwards take window slots ...")
+
+	// peer 0 owns 9: peer 1 forwards it, and every owner skips below it
+	// through a window of one.
+	pxa[1].Start(9, "nine")
+	for seq := 0; seq <= 9; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+		_, v := pxa[0].Status(seq)
+		if seq == 9 && v != "nine" {
+			t.Fatalf("Status(9) = %v, want: nine", v)
+		}
+		if seq < 9 && v != (Skip{}) {
+			t.Fatalf("Status(%d) = %v, want: Skip{}", seq, v)
+		}
+	}
+	for iters := 0; pxa[0].InFlight() > 0 || pxa[1].InFlight() > 0; iters++ {
+		if iters == 50 {
+			t.Fatalf("proposals still in flight after the decisions")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosMenciusManyProposers(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("mencius-many-proposers", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithMencius())
+	}
+
+	fmt.Println("Test: Mencius, many proposers ...")
+
+	// every peer starts every instance, most of them owned by someone else.
+	for seq := 0; seq < 6; seq++ {
+		for i := 0; i < npaxos; i++ {
+			pxa[i].Start(seq, 100*seq+i)
+		}
+	}
+	for seq := 0; seq < 6; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
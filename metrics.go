@@ -0,0 +1,184 @@
+package gopaxos
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics are kept in memory and served in the Prometheus text format by
+// MetricsHandler, without depending on a Prometheus client library. Mount
+// it wherever the process serves HTTP, e.g.
+//
+//	http.Handle("/metrics", px.MetricsHandler())
+
+// histogram counts observations into cumulative buckets.
+type histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64 // buckets[i] counts observations <= bounds[i]
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, buckets: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.bounds {
+		if v <= b {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, b, h.buckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, h.sum, name, h.count)
+}
+
+type metrics struct {
+	proposals atomic.Int64 // proposals started by this peer
+	decisions atomic.Int64 // instances this peer learned the decision of
+
+	// acceptor side
+	prepares, prepareRejects atomic.Int64
+	accepts, acceptRejects   atomic.Int64
+
+	rounds  *histogram // rounds this peer ran per instance it decided
+	latency *histogram // seconds from this peer's proposal to its decision
+
+	mu         sync.Mutex
+	started    map[int]time.Time // proposal start of undecided instances
+	peerErrors map[string]int64  // failed calls by peer address
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		rounds:     newHistogram(1, 2, 3, 5, 10, 20),
+		latency:    newHistogram(.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10),
+		started:    make(map[int]time.Time),
+		peerErrors: make(map[string]int64),
+	}
+}
+
+// proposed records that this peer started proposing in seq.
+func (m *metrics) proposed(seq int) {
+	m.proposals.Add(1)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.started[seq]; !ok {
+		m.started[seq] = time.Now()
+	}
+}
+
+// encodedSize returns the gob-encoded size of v, as counted by the
+// gopaxos_log_bytes gauge.
+func encodedSize(v Value) int64 {
+	var buf bytes.Buffer
+	gob.NewEncoder(&buf).Encode(&v)
+	return int64(buf.Len())
+}
+
+// decided observes the decision latency of seq if this peer proposed in it.
+// Callers count the decision itself, since a generalized instance is learned
+// more than once.
+func (m *metrics) decided(seq int) {
+	m.mu.Lock()
+	start, ok := m.started[seq]
+	delete(m.started, seq)
+	m.mu.Unlock()
+	if ok {
+		m.latency.observe(time.Since(start).Seconds())
+	}
+}
+
+// voted records an acceptor's answer to a prepare or an accept.
+func (m *metrics) voted(prepare, ok bool) {
+	total, rejects := &m.accepts, &m.acceptRejects
+	if prepare {
+		total, rejects = &m.prepares, &m.prepareRejects
+	}
+	total.Add(1)
+	if !ok {
+		rejects.Add(1)
+	}
+}
+
+func (m *metrics) peerError(peer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.peerErrors[peer]++
+}
+
+// MetricsHandler serves this peer's metrics in the Prometheus text format.
+func (p *Paxos) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		p.WriteMetrics(w)
+	})
+}
+
+// WriteMetrics writes this peer's metrics in the Prometheus text format.
+func (p *Paxos) WriteMetrics(w io.Writer) {
+	m := p.metrics
+	counter := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	gauge := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+
+	counter("gopaxos_proposals_total", "Proposals started by this peer.", m.proposals.Load())
+	counter("gopaxos_decisions_total", "Instances this peer learned the decision of.", m.decisions.Load())
+	counter("gopaxos_prepares_total", "Prepare requests this acceptor answered.", m.prepares.Load())
+	counter("gopaxos_prepare_rejects_total", "Prepare requests this acceptor rejected.", m.prepareRejects.Load())
+	counter("gopaxos_accepts_total", "Accept requests this acceptor answered.", m.accepts.Load())
+	counter("gopaxos_accept_rejects_total", "Accept requests this acceptor rejected.", m.acceptRejects.Load())
+	m.rounds.write(w, "gopaxos_rounds_per_decision", "Rounds this peer ran per instance it decided.")
+	m.latency.write(w, "gopaxos_decision_latency_seconds", "Time from this peer's proposal to the decision.")
+
+	p.mu.Lock()
+	gap := int64(p.maxSeq - p.minSeq)
+	entries := int64(len(p.logger.data))
+	size := p.logger.bytes
+	p.mu.Unlock()
+	gauge("gopaxos_instance_gap", "Max() - Min(), the instances this peer still holds.", gap)
+	gauge("gopaxos_log_entries", "Decided instances held by the commit log.", entries)
+	gauge("gopaxos_log_bytes", "Approximate gob-encoded size of the commit log.", size)
+
+	stats := p.RPCStats()
+	counter("gopaxos_rpc_calls_total", "Calls this peer made to other peers.", stats.Calls)
+	counter("gopaxos_rpc_timeouts_total", "Calls that timed out or were canceled.", stats.Timeouts)
+	counter("gopaxos_cluster_mismatches_total", "Requests rejected for coming from another cluster.", stats.ClusterMismatches)
+	counter("gopaxos_stale_epochs_total", "Requests rejected for a stale config epoch.", stats.StaleEpochs)
+
+	m.mu.Lock()
+	var peers []string
+	for peer := range m.peerErrors {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	fmt.Fprintf(w, "# HELP gopaxos_rpc_errors_total Failed calls to each peer.\n# TYPE gopaxos_rpc_errors_total counter\n")
+	for _, peer := range peers {
+		fmt.Fprintf(w, "gopaxos_rpc_errors_total{peer=%q} %d\n", peer, m.peerErrors[peer])
+	}
+	m.mu.Unlock()
+}
@@ -0,0 +1,110 @@
+package gopaxos
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGoPaxosHistogram(t *testing.T) {
+	fmt.Println("Test: Histogram buckets are cumulative ...")
+
+	h := newHistogram(1, 2, 5)
+	for _, v := range []float64{1, 2, 2, 4, 7} {
+		h.observe(v)
+	}
+	var buf bytes.Buffer
+	h.write(&buf, "x", "help")
+	for _, line := range []string{
+		`x_bucket{le="1"} 1`,
+		`x_bucket{le="2"} 3`,
+		`x_bucket{le="5"} 4`,
+		`x_bucket{le="+Inf"} 5`,
+		`x_sum 16`,
+		`x_count 5`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("missing %q in:\n%s", line, buf.String())
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosLogBytes(t *testing.T) {
+	fmt.Println("Test: Commit log counts its size as it is written ...")
+
+	cl := newCommitLog()
+	for _, e := range []struct {
+		seq int
+		v   Value
+	}{
+		{0, "a"},
+		{1, CStruct{"x"}},
+		// a generalized instance grows in place.
+		{1, CStruct{"x", "y"}},
+	} {
+		cl.Write(e.seq, e.v, encodedSize(e.v))
+	}
+	var want int64
+	for _, v := range cl.data {
+		want += encodedSize(v)
+	}
+	if cl.bytes != want {
+		t.Fatalf("log bytes = %d, want: %d", cl.bytes, want)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosMetrics(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("metrics", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: Metrics report proposals and decisions ...")
+
+	const ninst = 5
+	for seq := 0; seq < ninst; seq++ {
+		pxa[0].StartFast(seq, seq*10)
+	}
+	for seq := 0; seq < ninst; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	pxa[0].MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("content type %q, want: text/plain", ct)
+	}
+	out := rec.Body.String()
+	for _, line := range []string{
+		fmt.Sprintf("gopaxos_proposals_total %d", ninst),
+		fmt.Sprintf("gopaxos_decisions_total %d", ninst),
+		fmt.Sprintf("gopaxos_decision_latency_seconds_count %d", ninst),
+		fmt.Sprintf("gopaxos_rounds_per_decision_count %d", ninst),
+		fmt.Sprintf("gopaxos_log_entries %d", ninst),
+		"gopaxos_accept_rejects_total 0",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("missing %q in:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "gopaxos_accepts_total 0\n") {
+		t.Fatalf("no accepts counted:\n%s", out)
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -2,15 +2,17 @@
 package gopaxos
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
 	"math/rand"
-	"net"
-	"net/http"
-	"net/rpc"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // proposer(v):
@@ -44,11 +46,32 @@ import (
 //     reply accept_reject
 
 type commitLog struct {
-	data map[int]Value
+	data  map[int]Value
+	sizes map[int]int64 // approximate gob-encoded size of each value
+	next  int           // lowest seq not yet decided
+	max   int           // highest seq decided, -1 if none
+	bytes int64         // sum of sizes
+}
+
+func newCommitLog() *commitLog {
+	return &commitLog{data: make(map[int]Value), sizes: make(map[int]int64), max: -1}
 }
 
-func (cl *commitLog) Write(seq int, v Value) {
+// Write records v, of approximate encoded size size, as the value of seq.
+// The caller computes size, so the encoding need not happen under p.mu.
+func (cl *commitLog) Write(seq int, v Value, size int64) {
+	cl.bytes += size - cl.sizes[seq]
+	cl.sizes[seq] = size
 	cl.data[seq] = v
+	if seq > cl.max {
+		cl.max = seq
+	}
+	for {
+		if _, ok := cl.data[cl.next]; !ok {
+			break
+		}
+		cl.next++
+	}
 }
 
 func (cl *commitLog) Get(seq int) (Value, bool) {
@@ -64,6 +87,51 @@ type Paxos struct {
 	logger        *commitLog
 	mu            sync.Mutex
 
+	// membership
+	alpha    int   // 0 unless reconfiguration is enabled with WithAlpha
+	initial  int   // number of initial peers, see WithInitialPeers; 0 for all
+	learners []int // initial non-voting peers, see WithLearners
+	q1, q2   int   // initial phase-1 and phase-2 quorum sizes, see WithQuorums
+	quorums  QuorumSystem
+	configs  *configHistory
+	parked   map[int]func() // proposals waiting for their config to be known
+
+	fast    map[int]*fastInstance // acceptor state of round-based instances
+	rounds  map[int]int           // highest classic round proposed in, by seq
+	epaxos  *epaxosState
+	mencius *menciusState // nil unless enabled with WithMencius
+	general map[int]*generalInstance
+	commute Commute
+
+	clock Clock
+	lease leaseState
+	batch batchState
+
+	window    chan struct{} // proposal slots, nil unless WithWindow
+	inflight  map[int]int   // proposals of this peer not yet returned, by seq
+	storage   Storage       // of the acceptor state, nil unless WithStorage
+	transport Transport
+	tls       *tls.Config   // of the net/rpc transport, see WithTLS
+	idle      time.Duration // of the net/rpc transport, see WithIdleTimeout
+
+	rpcTimeout time.Duration
+	stats      rpcStats
+	keys       keyring
+	clusterID  string
+	metrics    *metrics
+
+	log   Logger
+	debug atomic.Bool // protocol traces, see SetDebug
+
+	listeners []EventListener
+	tracer    trace.Tracer
+
+	forgetBelow map[int]int // Forget of each peer by id, see Done
+
+	// ctx is canceled by Kill, which stops every proposal of this peer.
+	ctx  context.Context
+	stop context.CancelFunc
+
 	// state
 	minSeq int
 	maxSeq int
@@ -72,13 +140,107 @@ type Paxos struct {
 type Request struct {
 	FromID int
 	Seq    int
+	Round  int
+	Value  Value
+
+	// EPaxos command attributes, Seq carries the command's seq
+	Instance InstanceID
+	Deps     []InstanceID
+
+	// cluster membership, see Handler.Admit
+	ClusterID string
+	Epoch     int
+
+	// message authentication, see WithKeys
+	KeyID uint32
+	MAC   []byte
+
+	// W3C trace context of the caller's span, see WithTracerProvider
+	TraceParent string
+	TraceState  string
+
+	// the sender may forget every instance below Forget, see Done
+	Forget int
 }
 
 type Response struct {
+	OK      bool
+	Decided bool
+	Round   int
+	Value   Value
+
+	// EPaxos attributes merged by the replica
+	Seq  int
+	Deps []InstanceID
+
+	// message authentication, see WithKeys
+	KeyID uint32
+	MAC   []byte
+
+	// the replica may forget every instance below Forget, see Done
+	Forget int
 }
 
 type Value interface{}
 
+// Option configures a Paxos peer at construction time.
+type Option func(*Paxos)
+
+// WithAlpha enables membership reconfiguration through the log. A
+// ConfigChange decided in instance seq takes effect at seq+alpha, and a
+// proposer will not start an instance before its config is known. Every peer
+// in the cluster must use the same alpha.
+func WithAlpha(alpha int) Option {
+	if alpha < 1 {
+		panic(fmt.Sprintf("invalid alpha: %d, want: >= 1", alpha))
+	}
+	return func(p *Paxos) {
+		p.alpha = alpha
+	}
+}
+
+// WithInitialPeers starts a peer added by reconfiguration: its peers are
+// the n peers the cluster started with, followed by those added since in
+// the order their AddPeer or AddLearner changes were decided. Its configs
+// start from the first n peers, and it applies the changes that added the
+// others as it learns them, so it derives the same config, epoch included,
+// for every instance as the rest of the cluster.
+func WithInitialPeers(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("invalid initial peers: %d, want: >= 1", n))
+	}
+	return func(p *Paxos) {
+		p.initial = n
+	}
+}
+
+// WithLearners marks the given peers as learners: they receive every decided
+// value but never count toward a quorum. A learner becomes a voter through a
+// PromoteLearner reconfiguration.
+func WithLearners(ids ...int) Option {
+	return func(p *Paxos) {
+		p.learners = append(p.learners, ids...)
+	}
+}
+
+// WithQuorums sets the phase-1 (prepare) and phase-2 (accept) quorum sizes
+// for Flexible Paxos, e.g. q1 = 4 and q2 = 2 with five voters makes writes
+// cheap and leader changes expensive. Make panics unless q1 + q2 exceeds the
+// number of voters. A membership change that would break this is skipped.
+func WithQuorums(q1, q2 int) Option {
+	return func(p *Paxos) {
+		p.q1, p.q2 = q1, q2
+	}
+}
+
+// WithQuorumSystem replaces majority quorums with qs, e.g. Weighted or Grid.
+// Make panics if some prepare quorum of qs misses some accept quorum.
+func WithQuorumSystem(qs QuorumSystem) Option {
+	return func(p *Paxos) {
+		p.quorums = qs
+	}
+}
+
 // RPCs
 type Handler struct {
 	pxs *Paxos
@@ -88,17 +250,96 @@ func NewHandler(pxs *Paxos) *Handler {
 	return &Handler{pxs: pxs}
 }
 
+// OnReceiveProposal is the acceptor's prepare(n) handler for round
+// req.Round of instance req.Seq. It shares the round-based acceptor state
+// with StartFast, see OnReceiveFastPrepare.
 func (h *Handler) OnReceiveProposal(req *Request, response *Response) error {
-	fmt.Printf("Instance: %d, OnReceiveProposal: %+v\n", h.pxs.ID(), req)
-	return nil
+	return h.OnReceiveFastPrepare(req, response)
 }
 
+// OnReceiveAcceptance is the acceptor's accept(n, v) handler. Only classic
+// rounds are accepted here; the fast round goes through
+// OnReceiveFastAccept.
 func (h *Handler) OnReceiveAcceptance(req *Request, response *Response) error {
-	fmt.Printf("Instance: %d, OnReceiveAcceptance: %+v\n", h.pxs.ID(), req)
+	if req.Round == fastRound {
+		return fmt.Errorf("round %d of instance %d is not a classic round", req.Round, req.Seq)
+	}
+	return h.OnReceiveFastAccept(req, response)
+}
+
+func (h *Handler) OnReceiveDecision(req *Request, response *Response) error {
+	h.pxs.decide(req.Seq, req.Value)
+	return nil
+}
+
+// OnReceiveCatchUp lets a learner, or any peer that missed a Decided
+// broadcast, fetch the decided value of req.Seq.
+func (h *Handler) OnReceiveCatchUp(req *Request, response *Response) error {
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	response.Value, response.Decided = h.pxs.logger.Get(req.Seq)
 	return nil
 }
 
-func Make(peers []string, id int) *Paxos {
+// Snapshot calls send with every instance at or above from that this peer
+// has decided, in seq order, so a transport can stream a peer's log to one
+// that fell far behind. It stops at the first error of send.
+func (h *Handler) Snapshot(from int, send func(seq int, v Value) error) error {
+	h.pxs.mu.Lock()
+	var seqs []int
+	values := make(map[int]Value)
+	for seq, v := range h.pxs.logger.data {
+		if seq >= from {
+			seqs = append(seqs, seq)
+			values[seq] = v
+		}
+	}
+	h.pxs.mu.Unlock()
+
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		if err := send(seq, values[seq]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Install records the decided instances streamed by another peer's
+// Snapshot.
+func (p *Paxos) Install(seq int, v Value) {
+	p.decide(seq, v)
+}
+
+// New builds peer id of peers and serves its RPCs on peers[id]. It fails if
+// the transport cannot serve them, e.g. because the address is in use.
+// Invalid peers, id or options panic, as in Make.
+func New(peers []string, id int, opts ...Option) (*Paxos, error) {
+	pxs := newPaxos(peers, id, opts...)
+	if err := pxs.restoreAcceptors(); err != nil {
+		pxs.Kill()
+		return nil, err
+	}
+
+	if err := pxs.transport.Serve(peers[id], NewHandler(pxs)); err != nil {
+		pxs.Kill()
+		return nil, fmt.Errorf("gopaxos: serve %s: %w", peers[id], err)
+	}
+	return pxs, nil
+}
+
+// Make is New for callers that cannot handle an error: it panics if the
+// peer cannot serve its RPCs.
+func Make(peers []string, id int, opts ...Option) *Paxos {
+	pxs, err := New(peers, id, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return pxs
+}
+
+// newPaxos builds a peer without serving RPCs.
+func newPaxos(peers []string, id int, opts ...Option) *Paxos {
 	if id < 0 || id >= len(peers) {
 		panic(fmt.Sprintf("invalid set up, peers: %v, id: %d", peers, id))
 	}
@@ -106,82 +347,261 @@ func Make(peers []string, id int) *Paxos {
 		id:            id,
 		peers:         peers,
 		unreliableRPC: false,
-		logger:        &commitLog{data: make(map[int]Value)},
+		logger:        newCommitLog(),
+		parked:        make(map[int]func()),
+		fast:          make(map[int]*fastInstance),
+		rounds:        make(map[int]int),
+		inflight:      make(map[int]int),
+		epaxos:        newEPaxosState(),
+		general:       make(map[int]*generalInstance),
+		commute:       func(a, b Value) bool { return false },
+		clock:         realClock{},
+		transport:     newRPCTransport(),
+		rpcTimeout:    defaultRPCTimeout,
+		metrics:       newMetrics(),
+		log:           defaultLogger(),
+		tracer:        defaultTracer(),
+		batch:         batchState{size: 1},
+		forgetBelow:   make(map[int]int),
+	}
+	pxs.ctx, pxs.stop = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(pxs)
 	}
+	if t, ok := pxs.transport.(*rpcTransport); ok {
+		t.pool.tls = pxs.tls
+		if pxs.idle > 0 {
+			t.pool.idle = pxs.idle
+		}
+	} else if pxs.tls != nil || pxs.idle > 0 {
+		panic("invalid set up: WithTLS and WithIdleTimeout only configure the net/rpc transport, configure the one of WithTransport instead")
+	}
+	initial := peers
+	if pxs.initial > 0 {
+		if pxs.initial > len(peers) {
+			panic(fmt.Sprintf("invalid set up, peers: %v, initial peers: %d", peers, pxs.initial))
+		}
+		initial = peers[:pxs.initial]
+	}
+	if pxs.clusterID == "" {
+		pxs.clusterID = defaultClusterID(initial)
+	}
+	pxs.configs = newConfigHistory(initial, pxs.learners, pxs.q1, pxs.q2, pxs.quorums, pxs.alpha)
+	if err := pxs.configs.initial.check(); err != nil {
+		panic(fmt.Sprintf("invalid set up, peers: %v, id: %d: %v", peers, id, err))
+	}
+	return pxs
+}
 
-	server := rpc.NewServer()
-	server.Register(NewHandler(pxs))
-	addrAndPath := strings.Split(peers[id], "/")
-	if len(addrAndPath) != 2 {
-		panic(fmt.Sprintf("got: %v, want: ${HOSTNAME}:${PORT}/${RPC_PATH}", addrAndPath))
+// Start starts an agreement on v in instance seq and returns without
+// waiting for it, or under WithWindow once a window slot is free; Status
+// tells when it is decided. The proposer runs classic rounds, see the
+// pseudocode above, on the round-based acceptor state StartFast uses, so
+// the two may be mixed on one instance, with quorums counted by the
+// QuorumSystem of the instance's config. With reconfiguration enabled, an
+// instance whose config is not yet known is parked and started once every
+// instance at or below seq-alpha has been decided.
+func (p *Paxos) Start(seq int, v Value) {
+	p.mu.Lock()
+	config, ok := p.configFor(seq)
+	if !ok {
+		p.parked[seq] = func() { p.Start(seq, v) }
+		p.mu.Unlock()
+		return
+	}
+	if seq > p.maxSeq {
+		p.maxSeq = seq
+	}
+	p.mu.Unlock()
+
+	if p.mencius != nil {
+		p.propose(seq, func(ctx context.Context) { p.startMencius(ctx, config, seq, v) })
+		return
+	}
+	// prepare and accept only go to voters; learners just hear decisions.
+	// A StartFast or Submit in the same instance may have had its value
+	// chosen in the fast round, which pickFastValue keeps.
+	pick := func(promises []Response) Value {
+		return pickFastValue(promises, len(config.Voters()), config.FastQuorumSize(), v)
 	}
-	addr := addrAndPath[0]
-	rpcPath := addrAndPath[1]
+	p.propose(seq, func(ctx context.Context) { p.runClassicRounds(ctx, config, seq, 0, pick, config.IsQuorum) })
+}
 
-	http.Handle(rpcPath, server)
+// pickClassicValue returns the value of the highest round voted in among
+// the promises, or v if none voted.
+func pickClassicValue(promises []Response, v Value) Value {
+	k, w := -1, v
+	for _, resp := range promises {
+		if resp.Round > k {
+			k, w = resp.Round, resp.Value
+		}
+	}
+	return w
+}
 
-	listen, err := net.Listen("tcp", addr)
-	if err != nil {
-		log.Fatal("listen error:", err)
+// configFor returns the config that governs instance seq, or false if it
+// depends on instances that have not been decided yet. p.mu must be held.
+func (p *Paxos) configFor(seq int) (Config, bool) {
+	if p.alpha > 0 && seq-p.alpha >= p.logger.next {
+		return Config{}, false
 	}
-	go http.Serve(listen, nil)
+	return p.configs.at(seq), true
+}
 
-	return pxs
+// currentConfig returns the config of the lowest undecided instance, which
+// is always known. p.mu must be held.
+func (p *Paxos) currentConfig() Config {
+	config, _ := p.configFor(p.logger.next)
+	return config
 }
 
-// Start starts an agreement on new instance.
-func (p *Paxos) Start(seq int, v Value) {
-	var clients []*rpc.Client
-	for _, peer := range p.peers {
-		addrAndPath := strings.Split(peer, "/")
-		if len(addrAndPath) != 2 {
-			panic(fmt.Sprintf("got: %v, want: ${HOSTNAME}:${PORT}/${RPC_PATH}", addrAndPath))
+// checkVoter fails if this peer may not vote in instance req.Seq, or granted
+// a lease to a peer other than req.FromID.
+func (p *Paxos) checkVoter(req *Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if req.Seq < p.minSeq {
+		return fmt.Errorf("instance %d was forgotten", req.Seq)
+	}
+	config, ok := p.configFor(req.Seq)
+	if ok && !config.IsVoter(p.id) {
+		return fmt.Errorf("peer %d is not a voter of instance %d", p.id, req.Seq)
+	}
+	if p.leaseBlocks(req.FromID) {
+		return fmt.Errorf("peer %d granted a lease to peer %d", p.id, p.lease.holder)
+	}
+	return nil
+}
+
+// decide records v as the decided value of instance seq and starts any parked
+// proposals whose config became known.
+func (p *Paxos) decide(seq int, v Value) {
+	size := encodedSize(v)
+	p.mu.Lock()
+	if _, ok := p.logger.Get(seq); ok || seq < p.minSeq {
+		p.mu.Unlock()
+		return
+	}
+	p.logger.Write(seq, v, size)
+	p.metrics.decisions.Add(1)
+	p.metrics.decided(seq)
+	p.logDebug("decided", "seq", seq, "value", v)
+	p.emit(EventDecided, seq, 0, p.id, v)
+	if seq > p.maxSeq {
+		p.maxSeq = seq
+	}
+	var ready []int
+	if p.alpha > 0 {
+		if ch, ok := v.(ConfigChange); ok {
+			p.configs.record(seq, ch)
 		}
-		addr := addrAndPath[0]
-		rpcPath := addrAndPath[1]
-		client, err := rpc.DialHTTPPath("tcp", addr, rpcPath)
-		if err == nil {
-			clients = append(clients, client)
-		} else {
-			fmt.Printf("Error: %v\n", err)
-
-			clients = append(clients, nil)
+		for s := range p.parked {
+			if s-p.alpha < p.logger.next {
+				ready = append(ready, s)
+			}
 		}
 	}
+	starts := make([]func(), len(ready))
+	for i, s := range ready {
+		starts[i] = p.parked[s]
+		delete(p.parked, s)
+	}
+	p.mu.Unlock()
+
+	for _, start := range starts {
+		go start()
+	}
+}
+
+// Reconfigure proposes ch in instance seq. It fails if reconfiguration is not
+// enabled or ch is not valid against the latest known config; a change that
+// loses the race to a conflicting one is skipped when it is decided.
+func (p *Paxos) Reconfigure(seq int, ch ConfigChange) error {
+	p.mu.Lock()
+	if p.alpha == 0 {
+		p.mu.Unlock()
+		return fmt.Errorf("reconfiguration is not enabled, see WithAlpha")
+	}
+	_, err := p.configs.latest().apply(ch)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.Start(seq, ch)
+	return nil
+}
+
+// Config returns the config that governs instance seq, or false if it is not
+// known yet. Without reconfiguration every instance runs on the initial peers.
+func (p *Paxos) Config(seq int) (Config, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.configFor(seq)
+}
 
-	p.maxSeq++
-	for _, c := range clients {
-		if c != nil {
-			c.Go("Handler.OnReceiveProposal",
-				&Request{
-					FromID: p.ID(),
-					Seq:    p.Max(),
-				},
-				&Response{}, nil)
+// CatchUp asks the voters of instance seq for its decided value, and reports
+// whether seq is decided locally afterwards. Learners use it to fill gaps
+// left by missed Decided broadcasts.
+func (p *Paxos) CatchUp(seq int) bool {
+	if decided, _ := p.Status(seq); decided {
+		return true
+	}
+	config, ok := p.Config(seq)
+	if !ok {
+		return false
+	}
+	for _, id := range config.Voters() {
+		if id == p.id {
+			continue
+		}
+		var resp Response
+		err := p.callPeer(p.ctx, config.Peers[id], "Handler.OnReceiveCatchUp", &Request{FromID: p.ID(), Seq: seq}, &resp)
+		if err == nil && resp.Decided {
+			p.decide(seq, resp.Value)
+			return true
 		}
 	}
+	return false
 }
 
-// // Status gets info about an instance.
+// Status gets info about an instance. A forgotten instance is reported
+// as undecided.
 func (p *Paxos) Status(seq int) (bool, Value) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	v, ok := p.logger.Get(seq)
 	return ok, v
 }
 
-// Done means it is ok to forget all instances <= seq
-func (p *Paxos) Done(seq int) {}
+// Done means it is ok to forget all instances <= seq. They are forgotten
+// once every member of the cluster called Done on them, see Min.
+func (p *Paxos) Done(seq int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recordForget(p.id, seq+1)
+}
 
 // Max returns the highest instance seq known, or -1.
 func (p *Paxos) Max() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.maxSeq
 }
 
-// instances before this have been forgotten
+// Min returns the lowest instance this peer still holds: every member
+// called Done on the instances below it, so they have been forgotten.
 func (p *Paxos) Min() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.minSeq
 }
 
+// Kill stops the peer: proposals in flight give up, the transport is
+// closed with every connection it serves, and requests still arriving are
+// rejected, so the peer takes no further part in any instance.
 func (p *Paxos) Kill() {
+	p.stop()
+	p.transport.Close()
 }
 
 func (p *Paxos) ID() int {
@@ -200,6 +620,15 @@ func (p *Paxos) EnableReliableRPC() {
 	p.unreliableRPC = false
 }
 
+// splitPeerAddr splits a peer of the form ${HOSTNAME}:${PORT}/${RPC_PATH}.
+func splitPeerAddr(peer string) (string, string, error) {
+	addrAndPath := strings.Split(peer, "/")
+	if len(addrAndPath) != 2 {
+		return "", "", fmt.Errorf("got: %v, want: ${HOSTNAME}:${PORT}/${RPC_PATH}", addrAndPath)
+	}
+	return addrAndPath[0], addrAndPath[1], nil
+}
+
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
@@ -3,6 +3,7 @@ package gopaxos
 import (
 	"bytes"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"net"
 	"os"
@@ -10,8 +11,6 @@ import (
 	"strconv"
 	"testing"
 	"time"
-
-	"github.com/golang/glog"
 )
 
 func TestMain(m *testing.M) {
@@ -498,7 +497,9 @@ func TestGoPaxosManyInstances(t *testing.T) {
 		pxh[i] = port("many-instances", i)
 	}
 	for i := 0; i < npaxos; i++ {
-		pxa[i] = Make(pxh, i)
+		// only 5 active instances, to limit the
+		// number of file descriptors.
+		pxa[i] = Make(pxh, i, WithWindow(5))
 		pxa[i].Start(0, 0)
 	}
 
@@ -506,19 +507,6 @@ func TestGoPaxosManyInstances(t *testing.T) {
 
 	const ninst = 50
 	for seq := 1; seq < ninst; seq++ {
-		if seq >= 5 {
-			// only 5 active instances, to limit the
-			// number of file descriptors.
-			for {
-				count, err := ndecided(pxa, seq-5)
-				if err != nil {
-					t.Fatal(err)
-				}
-				if count < npaxos {
-					time.Sleep(20 * time.Millisecond)
-				}
-			}
-		}
 		for i := 0; i < npaxos; i++ {
 			pxa[i].Start(seq, (seq*10)+i)
 		}
@@ -600,7 +588,9 @@ func TestGoPaxosManyInstancesUnreliableRPC(t *testing.T) {
 		pxh[i] = port("many-instances-unreliable-rpc", i)
 	}
 	for i := 0; i < npaxos; i++ {
-		pxa[i] = Make(pxh, i)
+		// only 3 active instances, to limit the
+		// number of file descriptors.
+		pxa[i] = Make(pxh, i, WithWindow(3))
 		pxa[i].EnableUnReliableRPC()
 		pxa[i].Start(0, 0)
 	}
@@ -609,19 +599,6 @@ func TestGoPaxosManyInstancesUnreliableRPC(t *testing.T) {
 
 	const ninst = 50
 	for seq := 1; seq < ninst; seq++ {
-		// only 3 active instances, to limit the
-		// number of file descriptors.
-		if seq >= 3 {
-			for {
-				count, err := ndecided(pxa, seq-3)
-				if err != nil {
-					t.Fatal(err)
-				}
-				if count < npaxos {
-					time.Sleep(20 * time.Millisecond)
-				}
-			}
-		}
 		for i := 0; i < npaxos; i++ {
 			pxa[i].Start(seq, (seq*10)+i)
 		}
@@ -948,7 +925,8 @@ func TestGoPaxosManyRequestsChangingPartitions(t *testing.T) {
 				}
 			}
 			if err := makePartition(tag, npaxos, pa[0], pa[1], pa[2]); err != nil {
-				t.Fatal(err)
+				t.Error(err)
+				return
 			}
 			time.Sleep(time.Duration(rand.Int63()%200) * time.Millisecond)
 		}
@@ -966,7 +944,8 @@ func TestGoPaxosManyRequestsChangingPartitions(t *testing.T) {
 			for i := 0; i < seq; i++ {
 				count, err := ndecided(pxa, i)
 				if err != nil {
-					t.Fatal(err)
+					t.Error(err)
+					return
 				}
 				if count == npaxos {
 					nd++
@@ -989,7 +968,8 @@ func TestGoPaxosManyRequestsChangingPartitions(t *testing.T) {
 		for done == false {
 			for i := 0; i < seq; i++ {
 				if _, err := ndecided(pxa, i); err != nil {
-					t.Fatal(err)
+					t.Error(err)
+					return
 				}
 			}
 			time.Sleep(time.Duration(rand.Int63()%300) * time.Millisecond)
@@ -1043,7 +1023,7 @@ func TestGoPaxosConvergenceSpeed(t *testing.T) {
 	}
 
 	d := time.Since(t0)
-	fmt.Println("20 agreements %v seconds", d.Seconds())
+	fmt.Printf("20 agreements %v seconds\n", d.Seconds())
 }
 
 func port(tag string, host int) string {
@@ -1076,7 +1056,7 @@ func ndecided(pxa []*Paxos, seq int) (int, error) {
 		value   Value
 	}
 	if testing.Verbose() {
-		glog.Infof("Check states of instances, seq = %d", seq)
+		slog.Info("check states of instances", "seq", seq)
 	}
 	for i := 0; i < len(pxa); i++ {
 		decided, v := pxa[i].Status(seq)
@@ -1086,9 +1066,9 @@ func ndecided(pxa []*Paxos, seq int) (int, error) {
 		}{decided, v})
 		if testing.Verbose() {
 			if decided {
-				glog.Infof("instance [%d] has decided a value: %v", pxa[i].ID(), v)
+				slog.Info("decided", "peer", pxa[i].ID(), "seq", seq, "value", v)
 			} else {
-				glog.Infof("instance [%d] has not decided a value", pxa[i].ID())
+				slog.Info("not decided", "peer", pxa[i].ID(), "seq", seq)
 			}
 		}
 	}
@@ -1111,7 +1091,9 @@ func ndecided(pxa []*Paxos, seq int) (int, error) {
 
 func waitInstances(pxa []*Paxos, seq int, validateCount func(int) bool) (int, error) {
 	defer func(start time.Time) {
-		glog.Infof("wait instances took %s", time.Since(start))
+		if testing.Verbose() {
+			slog.Info("wait instances", "seq", seq, "took", time.Since(start))
+		}
 	}(time.Now())
 	to := 10 * time.Millisecond
 	count := -1
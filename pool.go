@@ -0,0 +1,232 @@
+package gopaxos
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// Every RPC to a peer goes through a clientPool, which keeps one persistent
+// client per peer shared by all goroutines; an rpc.Client multiplexes
+// concurrent calls over its connection. A client is dialed on first use.
+// A call that fails at the transport level drops the client, and the peer
+// is then redialed after an exponential backoff. Clients idle for longer
+// than the idle timeout are closed.
+
+const (
+	poolBackoffMin  = 10 * time.Millisecond
+	poolBackoffMax  = time.Second
+	poolIdleTimeout = time.Minute
+)
+
+// errBackoff is returned for a peer that is waiting to be redialed.
+var errBackoff = errors.New("gopaxos: peer unreachable, backing off")
+
+// peerClient is the pooled connection to one peer.
+type peerClient struct {
+	client   *rpc.Client // nil until dialed, and after a failure
+	failures int         // transport failures since the last success
+	retryAt  time.Time   // no dial before this
+	lastUsed time.Time
+}
+
+type clientPool struct {
+	mu    sync.Mutex
+	peers map[string]*peerClient
+	idle  time.Duration
+	done  chan struct{}
+	tls   *tls.Config // nil unless WithTLS
+}
+
+func newClientPool() *clientPool {
+	return &clientPool{
+		peers: make(map[string]*peerClient),
+		idle:  poolIdleTimeout,
+		done:  make(chan struct{}),
+	}
+}
+
+// WithIdleTimeout closes connections of the net/rpc transport to peers that
+// were not used for d. Make panics if it is combined with WithTransport.
+func WithIdleTimeout(d time.Duration) Option {
+	if d <= 0 {
+		panic("invalid idle timeout, want: d > 0")
+	}
+	return func(p *Paxos) {
+		p.idle = d
+	}
+}
+
+// get returns the client of peer, dialing it if needed. The dial runs
+// without cp.mu held, so a hung peer does not hold up calls to the others.
+func (cp *clientPool) get(ctx context.Context, peer string) (*rpc.Client, error) {
+	cp.mu.Lock()
+	select {
+	case <-cp.done:
+		cp.mu.Unlock()
+		return nil, rpc.ErrShutdown
+	default:
+	}
+	pc, ok := cp.peers[peer]
+	if !ok {
+		pc = &peerClient{}
+		cp.peers[peer] = pc
+	}
+	now := time.Now()
+	pc.lastUsed = now
+	if pc.client != nil {
+		defer cp.mu.Unlock()
+		return pc.client, nil
+	}
+	if now.Before(pc.retryAt) {
+		cp.mu.Unlock()
+		return nil, errBackoff
+	}
+	cp.mu.Unlock()
+
+	client, err := dialPeer(ctx, peer, cp.tls)
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if err != nil {
+		cp.backoff(pc, time.Now())
+		return nil, err
+	}
+	if pc.client != nil {
+		// another goroutine dialed first.
+		client.Close()
+		return pc.client, nil
+	}
+	pc.client, pc.failures = client, 0
+	return client, nil
+}
+
+// call invokes method on peer and waits for the reply until ctx is done. A
+// transport error drops the connection; an error returned by the handler
+// keeps it. A timeout only abandons this call: the connection stays up for
+// the other calls multiplexed on it, and a reply that arrives after ctx is
+// done is discarded.
+func (cp *clientPool) call(ctx context.Context, peer, method string, req *Request, resp *Response) error {
+	client, err := cp.get(ctx, peer)
+	if err != nil {
+		return err
+	}
+	var reply Response
+	select {
+	case call := <-client.Go(method, req, &reply, make(chan *rpc.Call, 1)).Done:
+		err = call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if _, ok := err.(rpc.ServerError); err != nil && !ok {
+		cp.fail(peer, client)
+	}
+	if err == nil {
+		*resp = reply
+	}
+	return err
+}
+
+// fail drops client after a transport error, unless it was already
+// replaced.
+func (cp *clientPool) fail(peer string, client *rpc.Client) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	pc := cp.peers[peer]
+	if pc == nil || pc.client != client {
+		return
+	}
+	client.Close()
+	pc.client = nil
+	cp.backoff(pc, time.Now())
+}
+
+// backoff doubles the wait before peer is redialed. cp.mu must be held.
+func (cp *clientPool) backoff(pc *peerClient, now time.Time) {
+	pc.failures++
+	wait := poolBackoffMax
+	if pc.failures < 8 {
+		if w := poolBackoffMin << uint(pc.failures-1); w < wait {
+			wait = w
+		}
+	}
+	pc.retryAt = now.Add(wait)
+}
+
+// healthy reports whether the last attempt to reach peer succeeded.
+func (cp *clientPool) healthy(peer string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	pc, ok := cp.peers[peer]
+	return ok && pc.failures == 0
+}
+
+// open returns the number of open connections.
+func (cp *clientPool) open() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	n := 0
+	for _, pc := range cp.peers {
+		if pc.client != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// closeIdle closes the clients not used since before cutoff.
+func (cp *clientPool) closeIdle(cutoff time.Time) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, pc := range cp.peers {
+		if pc.client != nil && pc.lastUsed.Before(cutoff) {
+			pc.client.Close()
+			pc.client = nil
+		}
+	}
+}
+
+// reap closes idle clients until the pool is closed.
+func (cp *clientPool) reap() {
+	ticker := time.NewTicker(cp.idle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cp.done:
+			return
+		case now := <-ticker.C:
+			cp.closeIdle(now.Add(-cp.idle))
+		}
+	}
+}
+
+// close stops the reaper and closes every client.
+func (cp *clientPool) close() {
+	cp.mu.Lock()
+	select {
+	case <-cp.done:
+	default:
+		close(cp.done)
+	}
+	cp.mu.Unlock()
+	cp.closeIdle(time.Now().Add(time.Hour))
+}
+
+// PeerHealthy reports whether this peer's last attempt to reach peer id of
+// the latest config over the net/rpc transport succeeded. It is always
+// false with another transport, and for an id that is not a member.
+func (p *Paxos) PeerHealthy(id int) bool {
+	t, ok := p.transport.(*rpcTransport)
+	if !ok {
+		return false
+	}
+	p.mu.Lock()
+	peers := p.configs.latest().Peers
+	p.mu.Unlock()
+	if id < 0 || id >= len(peers) || peers[id] == "" {
+		return false
+	}
+	return t.pool.healthy(peers[id])
+}
@@ -0,0 +1,76 @@
+package gopaxos
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClientPoolBacksOff(t *testing.T) {
+	fmt.Println("Test: Client pool backs off unreachable peers ...")
+
+	cp := newClientPool()
+	defer cp.close()
+	peer := "127.0.0.1:1/unreachable"
+	if _, err := cp.get(context.Background(), peer); err == nil || err == errBackoff {
+		t.Fatalf("first get() = %v, want: a dial error", err)
+	}
+	if _, err := cp.get(context.Background(), peer); err != errBackoff {
+		t.Fatalf("get() right after a failed dial = %v, want: %v", err, errBackoff)
+	}
+	if cp.healthy(peer) {
+		t.Fatalf("unreachable peer reported healthy")
+	}
+	time.Sleep(poolBackoffMin)
+	if _, err := cp.get(context.Background(), peer); err == errBackoff {
+		t.Fatalf("get() after the backoff did not redial")
+	}
+	if _, err := cp.get(context.Background(), peer); err != errBackoff {
+		t.Fatalf("get() = %v, want: %v", err, errBackoff)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosClientPool(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("client-pool", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithIdleTimeout(200*time.Millisecond))
+	}
+
+	fmt.Println("Test: Client pool reuses and closes connections ...")
+
+	for seq := 0; seq < 10; seq++ {
+		pxa[0].StartFast(seq, seq)
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool := pxa[0].transport.(*rpcTransport).pool
+	if n := pool.open(); n != npaxos {
+		t.Fatalf("%d connections open after 10 instances, want: %d", n, npaxos)
+	}
+	for i := 0; i < npaxos; i++ {
+		if !pxa[0].PeerHealthy(i) {
+			t.Fatalf("PeerHealthy(%d) = false, want: true", i)
+		}
+	}
+	if pxa[0].PeerHealthy(npaxos) {
+		t.Fatalf("PeerHealthy(%d) = true for a peer that is not a member", npaxos)
+	}
+
+	time.Sleep(time.Second)
+	if n := pool.open(); n != 0 {
+		t.Fatalf("%d connections open after the idle timeout, want: 0", n)
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,206 @@
+package gopaxos
+
+import "fmt"
+
+// Phase is the round of the protocol a quorum is collected for.
+type Phase int
+
+const (
+	// PhasePrepare collects promises, phase 1.
+	PhasePrepare Phase = iota + 1
+	// PhaseAccept collects accepts, phase 2.
+	PhaseAccept
+)
+
+func (ph Phase) String() string {
+	switch ph {
+	case PhasePrepare:
+		return "prepare"
+	case PhaseAccept:
+		return "accept"
+	}
+	return fmt.Sprintf("Phase(%d)", int(ph))
+}
+
+// QuorumSystem decides which sets of voters form a quorum. The proposer asks
+// it instead of comparing the number of replies against a majority.
+//
+// Every prepare quorum must intersect every accept quorum, and a superset of
+// a quorum must itself be a quorum. Acks from peers that are not voters must
+// be ignored.
+type QuorumSystem interface {
+	IsQuorum(phase Phase, voters []int, acks []int) bool
+}
+
+// Majority requires more than half of the voters in both phases.
+type Majority struct{}
+
+func (Majority) IsQuorum(phase Phase, voters []int, acks []int) bool {
+	return len(intersect(voters, acks)) > len(voters)/2
+}
+
+// Flexible requires Prepare voters in phase 1 and Accept voters in phase 2.
+// The quorums intersect as long as Prepare + Accept > len(voters).
+type Flexible struct {
+	Prepare int
+	Accept  int
+}
+
+func (f Flexible) IsQuorum(phase Phase, voters []int, acks []int) bool {
+	n := len(intersect(voters, acks))
+	if phase == PhasePrepare {
+		return n >= f.Prepare
+	}
+	return n >= f.Accept
+}
+
+// Weighted requires more than half of the total voting weight in both
+// phases. Voters without an entry in Weights weigh 1.
+type Weighted struct {
+	Weights map[int]int
+}
+
+func (w Weighted) weight(id int) int {
+	if v, ok := w.Weights[id]; ok {
+		return v
+	}
+	return 1
+}
+
+func (w Weighted) IsQuorum(phase Phase, voters []int, acks []int) bool {
+	total, acked := 0, 0
+	for _, id := range voters {
+		total += w.weight(id)
+	}
+	for _, id := range intersect(voters, acks) {
+		acked += w.weight(id)
+	}
+	return 2*acked > total
+}
+
+// Grid arranges voters in rows. A prepare quorum is one complete row and an
+// accept quorum is one voter from every row, so accepts stay cheap while any
+// row still meets every accept quorum. Voters outside the grid do not count.
+type Grid struct {
+	Rows [][]int
+}
+
+func (g Grid) IsQuorum(phase Phase, voters []int, acks []int) bool {
+	acked := intersect(voters, acks)
+	if phase == PhasePrepare {
+		for _, row := range g.Rows {
+			live := intersect(voters, row)
+			if len(live) > 0 && len(intersect(acked, live)) == len(live) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, row := range g.Rows {
+		live := intersect(voters, row)
+		if len(live) > 0 && len(intersect(acked, live)) == 0 {
+			return false
+		}
+	}
+	return len(acked) > 0
+}
+
+// Hierarchical groups voters, e.g. by rack, and requires a majority of the
+// groups, each with a majority of its voters, in both phases.
+type Hierarchical struct {
+	Groups [][]int
+}
+
+func (h Hierarchical) IsQuorum(phase Phase, voters []int, acks []int) bool {
+	acked := intersect(voters, acks)
+	groups, ok := 0, 0
+	for _, group := range h.Groups {
+		live := intersect(voters, group)
+		if len(live) == 0 {
+			continue
+		}
+		groups++
+		if len(intersect(acked, live)) > len(live)/2 {
+			ok++
+		}
+	}
+	return ok > groups/2
+}
+
+// maxCheckedVoters bounds the exhaustive intersection check.
+const maxCheckedVoters = 16
+
+// checkIntersection fails if some prepare quorum of qs misses some accept
+// quorum. Since quorums are closed under supersets, it is enough to check
+// that the voters left out of a prepare quorum never form an accept quorum.
+// Larger configurations are trusted as is.
+func checkIntersection(qs QuorumSystem, voters []int) error {
+	n := len(voters)
+	if n > maxCheckedVoters {
+		return nil
+	}
+	for mask := 0; mask < 1<<uint(n); mask++ {
+		var in, out []int
+		for i, id := range voters {
+			if mask&(1<<uint(i)) != 0 {
+				in = append(in, id)
+			} else {
+				out = append(out, id)
+			}
+		}
+		if qs.IsQuorum(PhasePrepare, voters, in) && qs.IsQuorum(PhaseAccept, voters, out) {
+			return fmt.Errorf("prepare quorum %v misses accept quorum %v", in, out)
+		}
+	}
+	if !qs.IsQuorum(PhasePrepare, voters, voters) || !qs.IsQuorum(PhaseAccept, voters, voters) {
+		return fmt.Errorf("voters %v form no quorum", voters)
+	}
+	return nil
+}
+
+// minQuorumSize returns the size of the smallest quorum of qs for phase.
+// Beyond maxCheckedVoters a system other than Majority or Flexible is
+// assumed to have quorums of a single voter, which is always safe for the
+// callers.
+func minQuorumSize(qs QuorumSystem, phase Phase, voters []int) int {
+	n := len(voters)
+	switch q := qs.(type) {
+	case Majority:
+		return n/2 + 1
+	case Flexible:
+		if phase == PhasePrepare {
+			return q.Prepare
+		}
+		return q.Accept
+	}
+	if n > maxCheckedVoters {
+		return 1
+	}
+	best := n
+	for mask := 1; mask < 1<<uint(n); mask++ {
+		var in []int
+		for i, id := range voters {
+			if mask&(1<<uint(i)) != 0 {
+				in = append(in, id)
+			}
+		}
+		if len(in) < best && qs.IsQuorum(phase, voters, in) {
+			best = len(in)
+		}
+	}
+	return best
+}
+
+// intersect returns the ids of a that also appear in b, in the order of a.
+func intersect(a, b []int) []int {
+	var out []int
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				out = append(out, x)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package gopaxos
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQuorumSystems(t *testing.T) {
+	fmt.Println("Test: Quorum systems ...")
+
+	voters := []int{0, 1, 2, 3, 4, 5}
+	tests := []struct {
+		name  string
+		qs    QuorumSystem
+		phase Phase
+		acks  []int
+		want  bool
+	}{
+		{"majority", Majority{}, PhasePrepare, []int{0, 1, 2}, false},
+		{"majority", Majority{}, PhaseAccept, []int{0, 1, 2, 3}, true},
+		{"majority ignores non-voters", Majority{}, PhaseAccept, []int{0, 1, 2, 7, 8}, false},
+		{"flexible", Flexible{Prepare: 5, Accept: 2}, PhasePrepare, []int{0, 1, 2, 3}, false},
+		{"flexible", Flexible{Prepare: 5, Accept: 2}, PhaseAccept, []int{4, 5}, true},
+		{"weighted", Weighted{Weights: map[int]int{0: 5}}, PhaseAccept, []int{0, 1}, true},
+		{"weighted", Weighted{Weights: map[int]int{0: 5}}, PhaseAccept, []int{1, 2, 3, 4, 5}, false},
+		{"grid row", Grid{Rows: [][]int{{0, 1, 2}, {3, 4, 5}}}, PhasePrepare, []int{3, 4, 5}, true},
+		{"grid row", Grid{Rows: [][]int{{0, 1, 2}, {3, 4, 5}}}, PhasePrepare, []int{0, 1, 3, 4}, false},
+		{"grid column", Grid{Rows: [][]int{{0, 1, 2}, {3, 4, 5}}}, PhaseAccept, []int{2, 3}, true},
+		{"grid column", Grid{Rows: [][]int{{0, 1, 2}, {3, 4, 5}}}, PhaseAccept, []int{0, 1, 2}, false},
+		{"hierarchical", Hierarchical{Groups: [][]int{{0, 1}, {2, 3}, {4, 5}}}, PhaseAccept, []int{0, 1, 2, 3}, true},
+		{"hierarchical", Hierarchical{Groups: [][]int{{0, 1}, {2, 3}, {4, 5}}}, PhaseAccept, []int{0, 1, 2, 4}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.qs.IsQuorum(tt.phase, voters, tt.acks); got != tt.want {
+			t.Fatalf("%s: IsQuorum(%v, %v) = %v, want: %v", tt.name, tt.phase, tt.acks, got, tt.want)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestQuorumSystemsIntersect(t *testing.T) {
+	fmt.Println("Test: Quorum systems intersect ...")
+
+	voters := []int{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	for _, qs := range []QuorumSystem{
+		Majority{},
+		Flexible{Prepare: 7, Accept: 3},
+		Weighted{Weights: map[int]int{0: 3, 1: 3, 2: 2}},
+		Grid{Rows: [][]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}}},
+		Hierarchical{Groups: [][]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}}},
+	} {
+		if err := checkIntersection(qs, voters); err != nil {
+			t.Fatalf("%#v: %v", qs, err)
+		}
+	}
+
+	if err := checkIntersection(Flexible{Prepare: 4, Accept: 5}, voters); err == nil {
+		t.Fatalf("disjoint flexible quorums passed the check")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosQuorumSystemValidated(t *testing.T) {
+	fmt.Println("Test: Quorum system is validated at construction ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p", "d:1/p"}
+	pxs := newPaxos(peers, 0, WithQuorumSystem(Grid{Rows: [][]int{{0, 1}, {2, 3}}}))
+	c, _ := pxs.Config(0)
+	if !c.IsQuorum(PhaseAccept, []int{1, 2}) {
+		t.Fatalf("IsQuorum(accept, [1 2]) = false, want: true")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("newPaxos with disjoint quorums did not panic")
+		}
+	}()
+	newPaxos(peers, 0, WithQuorumSystem(Flexible{Prepare: 2, Accept: 2}))
+}
@@ -0,0 +1,94 @@
+package gopaxos
+
+import (
+	"context"
+	"time"
+)
+
+// ReadBarrier and WaitApplied implement ReadIndex-style linearizable reads
+// that write nothing to the log. There is no distinguished leader to
+// confirm, so the barrier asks a prepare quorum of voters for the highest
+// instance they voted in or learned. Every decided instance was accepted
+// by an accept quorum, and every accept quorum meets every prepare quorum.
+// So the barrier is at least as high as every decision that completed
+// before ReadBarrier was called. A replica that has applied every instance
+// up to the barrier may then answer the read from local state.
+//
+// The barrier may cover holes: instances nobody has proposed in yet, or
+// whose proposer died after some voters voted. A read writes nothing, so
+// WaitApplied does not fill them; it waits until a proposal decides them,
+// and fails when its context is done first. A caller that picks instances
+// itself should start the instances below the ones it reads from.
+
+// ReadBarrier returns an instance seq such that every value decided before
+// the call is at or below seq.
+func (p *Paxos) ReadBarrier(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	config := p.currentConfig()
+	p.mu.Unlock()
+
+	replies := p.callPeers(ctx, config, config.Voters(), "Handler.OnReceiveReadIndex", &Request{FromID: p.ID()})
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	if !config.IsQuorum(PhasePrepare, acked(replies)) {
+		return -1, ErrNoQuorum
+	}
+	barrier := -1
+	for _, resp := range replies {
+		if resp.OK && resp.Seq > barrier {
+			barrier = resp.Seq
+		}
+	}
+	return barrier, nil
+}
+
+// WaitApplied blocks until every instance up to seq is decided locally,
+// fetching the ones this peer missed from the voters. It proposes nothing:
+// an instance nobody proposes in keeps it waiting until ctx is done.
+func (p *Paxos) WaitApplied(ctx context.Context, seq int) error {
+	for {
+		p.mu.Lock()
+		next := p.logger.next
+		p.mu.Unlock()
+		if next > seq {
+			return nil
+		}
+		if p.CatchUp(next) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// highestVoted returns the highest instance this peer voted in or learned
+// the decision of. p.mu must be held.
+func (p *Paxos) highestVoted() int {
+	highest := p.logger.max
+	for seq, inst := range p.fast {
+		if inst.vrnd >= 0 && seq > highest {
+			highest = seq
+		}
+	}
+	for seq, inst := range p.general {
+		if inst.vrnd >= 0 && seq > highest {
+			highest = seq
+		}
+	}
+	return highest
+}
+
+// OnReceiveReadIndex reports the highest instance this voter voted in.
+func (h *Handler) OnReceiveReadIndex(req *Request, response *Response) error {
+	h.pxs.mu.Lock()
+	defer h.pxs.mu.Unlock()
+	if h.pxs.currentConfig().IsVoter(h.pxs.id) {
+		response.OK = true
+		response.Seq = h.pxs.highestVoted()
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package gopaxos
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReadIndexReportsHighestVote(t *testing.T) {
+	fmt.Println("Test: Voter reports its highest vote as read index ...")
+
+	pxs := newPaxos([]string{"a:1/p", "b:1/p", "c:1/p"}, 2)
+	h := NewHandler(pxs)
+
+	var resp Response
+	h.OnReceiveReadIndex(&Request{FromID: 0}, &resp)
+	if !resp.OK || resp.Seq != -1 {
+		t.Fatalf("read index of an empty voter = %v, %d, want: true, -1", resp.OK, resp.Seq)
+	}
+
+	// a vote counts even though the decision has not arrived.
+	h.OnReceiveDecision(&Request{FromID: 0, Seq: 2, Value: "x"}, &Response{})
+	h.OnReceiveFastAccept(&Request{FromID: 0, Seq: 5, Round: fastRound, Value: "y"}, &Response{})
+	resp = Response{}
+	h.OnReceiveReadIndex(&Request{FromID: 0}, &resp)
+	if resp.Seq != 5 {
+		t.Fatalf("read index = %d, want: 5", resp.Seq)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosReadBarrier(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("read-barrier", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: ReadIndex barrier covers every decision ...")
+
+	for seq := 0; seq < 3; seq++ {
+		pxa[0].StartFast(seq, seq*10)
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	barrier, err := pxa[2].ReadBarrier(ctx)
+	if err != nil || barrier != 2 {
+		t.Fatalf("ReadBarrier() = %d, %v, want: 2, nil", barrier, err)
+	}
+	if err := pxa[2].WaitApplied(ctx, barrier); err != nil {
+		t.Fatalf("WaitApplied(%d) = %v", barrier, err)
+	}
+	if decided, v := pxa[2].Status(barrier); !decided || v != 20 {
+		t.Fatalf("Status(%d) = %v, %v, want: true, 20", barrier, decided, v)
+	}
+
+	cancel()
+	if err := pxa[2].WaitApplied(ctx, 10); err != context.Canceled {
+		t.Fatalf("WaitApplied() after cancel = %v, want: %v", err, context.Canceled)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosReadBarrierWaitsForHoles(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("read-barrier-holes", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: ReadIndex barrier waits for holes ...")
+
+	// seqs 1 and 2 were never used.
+	pxa[0].StartFast(0, "a")
+	pxa[0].StartFast(3, "b")
+	if err := waitN(pxa, 3, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	barrier, err := pxa[2].ReadBarrier(ctx)
+	if err != nil || barrier != 3 {
+		t.Fatalf("ReadBarrier() = %d, %v, want: 3, nil", barrier, err)
+	}
+	short, cancelShort := context.WithTimeout(ctx, time.Second)
+	defer cancelShort()
+	if err := pxa[2].WaitApplied(short, barrier); err != context.DeadlineExceeded {
+		t.Fatalf("WaitApplied(%d) over holes = %v, want: %v", barrier, err, context.DeadlineExceeded)
+	}
+	for seq := 1; seq <= 2; seq++ {
+		if n, err := ndecided(pxa, seq); n != 0 || err != nil {
+			t.Fatalf("read decided hole %d: %v", seq, err)
+		}
+	}
+
+	// the reads wrote nothing, so later proposals still get their values.
+	pxa[1].Start(1, 100)
+	pxa[0].Start(2, 300)
+	if err := pxa[2].WaitApplied(ctx, barrier); err != nil {
+		t.Fatalf("WaitApplied(%d) = %v", barrier, err)
+	}
+	for seq, want := range map[int]Value{1: 100, 2: 300} {
+		if decided, v := pxa[2].Status(seq); !decided || v != want {
+			t.Fatalf("Status(%d) = %v, %v, want: true, %v", seq, decided, v, want)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosWaitAppliedLeavesGeneralizedHoles(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("wait-applied-generalized", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: WaitApplied leaves generalized holes ...")
+
+	// peer 1 voted for a command whose proposer died.
+	NewHandler(pxa[1]).OnReceiveCStructAppend(&Request{FromID: 0, Seq: 0, Round: fastRound, Value: "x"}, &Response{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pxa[1].WaitApplied(ctx, 0); err != context.DeadlineExceeded {
+		t.Fatalf("WaitApplied(0) = %v, want: %v", err, context.DeadlineExceeded)
+	}
+	if n, err := ndecided(pxa, 0); n != 0 || err != nil {
+		t.Fatalf("read decided hole 0: %v", err)
+	}
+
+	pxa[1].AppendCommand(0, "y")
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pxa[1].WaitApplied(ctx, 0); err != nil {
+		t.Fatalf("WaitApplied(0) = %v", err)
+	}
+	if decided, v := pxa[1].Status(0); !decided || !toCStruct(v).contains("y") {
+		t.Fatalf("Status(0) = %v, %v, want: true, [... y]", decided, v)
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,196 @@
+package gopaxos
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRPCTimeout bounds every call to a peer unless WithRPCTimeout
+// changes it.
+const defaultRPCTimeout = 2 * time.Second
+
+// RPCStats counts the calls this peer made to other peers, and the
+// requests rejected for coming from the wrong cluster or config.
+type RPCStats struct {
+	Calls    int64 `json:"calls"`    // calls attempted
+	Failures int64 `json:"failures"` // calls that failed, timeouts included
+	Timeouts int64 `json:"timeouts"` // calls that timed out or were canceled
+
+	// requests this peer rejected or that peers rejected from it
+	ClusterMismatches int64 `json:"cluster_mismatches"`
+	StaleEpochs       int64 `json:"stale_epochs"`
+}
+
+type rpcStats struct {
+	calls, failures, timeouts      atomic.Int64
+	clusterMismatches, staleEpochs atomic.Int64
+}
+
+// WithRPCTimeout bounds each call to a peer to d.
+func WithRPCTimeout(d time.Duration) Option {
+	if d <= 0 {
+		panic("invalid rpc timeout, want: d > 0")
+	}
+	return func(p *Paxos) {
+		p.rpcTimeout = d
+	}
+}
+
+// RPCStats returns counters of the calls this peer made.
+func (p *Paxos) RPCStats() RPCStats {
+	return RPCStats{
+		Calls:    p.stats.calls.Load(),
+		Failures: p.stats.failures.Load(),
+		Timeouts: p.stats.timeouts.Load(),
+
+		ClusterMismatches: p.stats.clusterMismatches.Load(),
+		StaleEpochs:       p.stats.staleEpochs.Load(),
+	}
+}
+
+// callPeer invokes method on peer, giving up after the rpc timeout or when
+// ctx is done, whichever comes first. Under WithKeys it signs req and
+// rejects a response whose MAC does not verify.
+func (p *Paxos) callPeer(ctx context.Context, peer, method string, req *Request, resp *Response) error {
+	ctx, cancel := context.WithTimeout(ctx, p.rpcTimeout)
+	defer cancel()
+	p.stats.calls.Add(1)
+	stamped := *req
+	stamped.ClusterID = p.clusterID
+	p.mu.Lock()
+	stamped.Forget = p.forgetBelow[p.id]
+	p.mu.Unlock()
+	ctx, span := p.startCall(ctx, peer, method, &stamped)
+	req = p.signRequest(method, &stamped)
+	var reply Response
+	err := p.remoteError(p.transport.Call(ctx, peer, method, req, &reply))
+	if err == nil {
+		err = p.verifyResponse(method, req, &reply)
+	}
+	if err == nil {
+		*resp = reply
+	} else {
+		p.stats.failures.Add(1)
+		p.metrics.peerError(peer)
+		p.logDebug("call failed", "peer", peer, "method", method, "seq", req.Seq, "round", req.Round, "err", err)
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			p.stats.timeouts.Add(1)
+		}
+	}
+	endSpan(span, err)
+	return err
+}
+
+// dialPeer connects to the RPC server Make starts for peer. It is
+// rpc.DialHTTPPath, except that the dial and the HTTP handshake give up
+// when ctx is done, and that it runs over TLS if config is not nil.
+func dialPeer(ctx context.Context, peer string, config *tls.Config) (*rpc.Client, error) {
+	addr, rpcPath, err := splitPeerAddr(peer)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if config != nil {
+		config = config.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, config)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		conn = tc
+	}
+	io.WriteString(conn, "CONNECT /"+rpcPath+" HTTP/1.0\n\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status != "200 Connected to Go RPC" {
+		err = errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	if err != nil {
+		conn.Close()
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			// the handshake hit the deadline taken from ctx.
+			return nil, context.DeadlineExceeded
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return rpc.NewClient(conn), nil
+}
+
+// callVoters sends req to every voter of config, this peer included, and
+// returns the replies of those that answered, keyed by peer id.
+func (p *Paxos) callVoters(ctx context.Context, config Config, method string, req *Request) map[int]Response {
+	return p.callPeers(ctx, config, config.Voters(), method, req)
+}
+
+// callPeers sends req to the peers ids of config in parallel and returns the
+// replies of those that answered, keyed by peer id, noting their Forget. It
+// gives up on every peer that has not answered when ctx is done.
+func (p *Paxos) callPeers(ctx context.Context, config Config, ids []int, method string, req *Request) map[int]Response {
+	stamped := *req
+	stamped.Epoch = config.Epoch
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	replies := make(map[int]Response)
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id int, peer string) {
+			defer wg.Done()
+			var resp Response
+			if err := p.callPeer(ctx, peer, method, &stamped, &resp); err != nil {
+				return
+			}
+			mu.Lock()
+			replies[id] = resp
+			mu.Unlock()
+			p.mu.Lock()
+			p.recordForget(id, resp.Forget)
+			p.mu.Unlock()
+		}(id, config.Peers[id])
+	}
+	wg.Wait()
+	return replies
+}
+
+// broadcastDecision records v locally and tells every member, learners
+// included, that v was decided in instance seq.
+func (p *Paxos) broadcastDecision(ctx context.Context, config Config, seq int, v Value) {
+	p.decide(seq, v)
+	others := removeID(config.Members(), p.ID())
+	p.callPeers(ctx, config, others, "Handler.OnReceiveDecision", &Request{FromID: p.ID(), Seq: seq, Value: v})
+}
+
+// acked returns the ids whose reply is OK.
+func acked(replies map[int]Response) []int {
+	var ids []int
+	for id, resp := range replies {
+		if resp.OK {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
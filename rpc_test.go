@@ -0,0 +1,150 @@
+package gopaxos
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/rpc"
+	"testing"
+	"time"
+)
+
+// slowHandler answers every call after a delay.
+type slowHandler struct {
+	delay time.Duration
+}
+
+func (h *slowHandler) OnReceiveDecision(req *Request, response *Response) error {
+	time.Sleep(h.delay)
+	response.OK = true
+	return nil
+}
+
+func TestRPCTimeouts(t *testing.T) {
+	fmt.Println("Test: Calls to hung peers time out ...")
+
+	// a peer that accepts connections and never answers.
+	hung, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hung.Close()
+	go func() {
+		for {
+			if _, err := hung.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// a peer that answers too late.
+	server := rpc.NewServer()
+	server.RegisterName("Handler", &slowHandler{delay: 300 * time.Millisecond})
+	mux := http.NewServeMux()
+	mux.Handle("/slow", server)
+	slow, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	go http.Serve(slow, mux)
+
+	pxs := newPaxos([]string{"a:1/p", hung.Addr().String() + "/p", slow.Addr().String() + "/slow"}, 0,
+		WithRPCTimeout(100*time.Millisecond))
+	defer pxs.Kill()
+
+	for _, peer := range pxs.peers[1:] {
+		start := time.Now()
+		var resp Response
+		err := pxs.callPeer(context.Background(), peer, "Handler.OnReceiveDecision", &Request{}, &resp)
+		if err != context.DeadlineExceeded {
+			t.Fatalf("call to %s = %v, want: %v", peer, err, context.DeadlineExceeded)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("call to %s took %v, want: about the 100ms timeout", peer, d)
+		}
+		if resp.OK {
+			t.Fatalf("late reply from %s was not discarded", peer)
+		}
+	}
+	if stats := pxs.RPCStats(); stats.Calls != 2 || stats.Timeouts != 2 {
+		t.Fatalf("RPCStats() = %+v, want: 2 calls, 2 timeouts", stats)
+	}
+
+	// the timeout abandoned the call, not the connection it shared.
+	if n := pxs.transport.(*rpcTransport).pool.open(); n != 1 {
+		t.Fatalf("%d connections open after the timeouts, want: 1 to the slow peer", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var late Response
+	if err := pxs.transport.Call(ctx, pxs.peers[2], "Handler.OnReceiveDecision", &Request{}, &late); err != nil || !late.OK {
+		t.Fatalf("call on the kept connection = %v, OK %v", err, late.OK)
+	}
+
+	// a canceled context gives up before the timeout.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	time.Sleep(poolBackoffMax)
+	var resp Response
+	if err := pxs.callPeer(ctx, pxs.peers[2], "Handler.OnReceiveDecision", &Request{}, &resp); err != context.Canceled {
+		t.Fatalf("call with a canceled context = %v, want: %v", err, context.Canceled)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestKillStopsProposals(t *testing.T) {
+	fmt.Println("Test: Kill stops proposals that cannot decide ...")
+
+	// the other voters never answer, so the proposal retries until Kill.
+	pxh := []string{port("kill-stops", 0), "127.0.0.1:1/p", "127.0.0.1:2/p"}
+	pxs := Make(pxh, 0, WithWindow(1), WithRPCTimeout(50*time.Millisecond))
+	pxs.StartFast(0, "x")
+	time.Sleep(200 * time.Millisecond)
+	if n := pxs.InFlight(); n != 1 {
+		t.Fatalf("InFlight() = %d before Kill, want: 1", n)
+	}
+
+	pxs.Kill()
+	for start := time.Now(); pxs.InFlight() > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("proposal still running after Kill")
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosKilledPeersStopAnswering(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("killed-stop-answering", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i)
+	}
+
+	fmt.Println("Test: Killed peers answer no more RPCs ...")
+
+	// peer 0 now has open connections to the others.
+	pxa[0].Start(0, "before")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	pxa[1].Kill()
+	pxa[2].Kill()
+	pxa[0].Start(1, "after")
+	time.Sleep(time.Second)
+	if decided, _ := pxa[0].Status(1); decided {
+		t.Fatalf("instance 1 decided with two of three peers killed")
+	}
+
+	fmt.Println("  ... Passed")
+}
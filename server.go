@@ -0,0 +1,178 @@
+package gopaxos
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net/http"
+	"net/rpc"
+	"sync"
+)
+
+// rpcServer is rpc.Server.ServeHTTP with a codec that checks every request
+// before the Handler sees it: its client certificate under mutual TLS, and
+// Handler.Admit. Responses are signed on the way out.
+type rpcServer struct {
+	server      *rpc.Server
+	h           *Handler
+	requireCert bool
+	t           *rpcTransport // closes the hijacked connections
+}
+
+func (s *rpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	var cert *x509.Certificate
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		cert = r.TLS.PeerCertificates[0]
+	}
+	if s.requireCert && cert == nil {
+		http.Error(w, "client certificate required", http.StatusUnauthorized)
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	if !s.t.track(conn) {
+		conn.Close()
+		return
+	}
+	defer s.t.untrack(conn)
+	io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+	s.server.ServeCodec(&checkedCodec{
+		gobServerCodec: newGobServerCodec(conn),
+		h:              s.h,
+		cert:           cert,
+		pending:        make(map[uint64]pendingRequest),
+	})
+}
+
+// checkedCodec rejects the requests that fail the checks of rpcServer.
+// net/rpc answers them with the error and keeps serving the connection.
+type checkedCodec struct {
+	*gobServerCodec
+	h    *Handler
+	cert *x509.Certificate // nil without TLS
+
+	header rpc.Request // of the request being read
+
+	mu      sync.Mutex
+	pending map[uint64]pendingRequest // requests being served, by rpc seq
+}
+
+type pendingRequest struct {
+	req *Request
+	end func(error) // ends the span serving req
+}
+
+func (c *checkedCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.gobServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	c.header = *r
+	return nil
+}
+
+func (c *checkedCodec) ReadRequestBody(body any) error {
+	if err := c.gobServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	if body == nil {
+		return nil
+	}
+	req, ok := body.(*Request)
+	if !ok {
+		return errors.New("unexpected request type")
+	}
+	if c.cert != nil {
+		if err := c.h.Authorize(c.cert, req.FromID); err != nil {
+			return err
+		}
+	}
+	if err := c.h.Admit(c.header.ServiceMethod, req); err != nil {
+		return err
+	}
+	end := c.h.StartSpan(c.header.ServiceMethod, req)
+	c.mu.Lock()
+	c.pending[c.header.Seq] = pendingRequest{req, end}
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *checkedCodec) WriteResponse(r *rpc.Response, body any) error {
+	c.mu.Lock()
+	pending, ok := c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.mu.Unlock()
+	if !ok {
+		return c.gobServerCodec.WriteResponse(r, body)
+	}
+	if r.Error != "" {
+		pending.end(errors.New(r.Error))
+		return c.gobServerCodec.WriteResponse(r, body)
+	}
+	pending.end(nil)
+	if resp, ok := body.(*Response); ok {
+		c.h.SignResponse(r.ServiceMethod, pending.req, resp)
+	}
+	return c.gobServerCodec.WriteResponse(r, body)
+}
+
+// gobServerCodec is the codec net/rpc uses on every connection, which it
+// does not export.
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) *gobServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body any) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body any) error {
+	if err := c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,40 @@
+package gopaxos
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// A snapshot file is a gob stream of snapshotEntry, one per decided
+// instance in seq order. Values must be gob-registered like any Value sent
+// to a peer.
+type snapshotEntry struct {
+	Seq   int
+	Value Value
+}
+
+// WriteSnapshot writes every instance at or above from that this peer has
+// decided to w.
+func (p *Paxos) WriteSnapshot(w io.Writer, from int) error {
+	enc := gob.NewEncoder(w)
+	return NewHandler(p).Snapshot(from, func(seq int, v Value) error {
+		return enc.Encode(snapshotEntry{Seq: seq, Value: v})
+	})
+}
+
+// InstallSnapshot records the decided instances of a snapshot written by
+// WriteSnapshot, e.g. to seed a new or lagging peer.
+func (p *Paxos) InstallSnapshot(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	for {
+		var e snapshotEntry
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		p.Install(e.Seq, e.Value)
+	}
+}
@@ -0,0 +1,87 @@
+package gopaxos
+
+import "fmt"
+
+// By default a peer keeps its acceptor state in memory only. A voter that
+// restarts without it forgets its promises and votes, and may help choose
+// a second value in an instance it voted in before. WithStorage makes the
+// state of round-based and generalized instances durable: the prepare and
+// accept handlers save the state they changed before they answer, and New
+// restores the saved states before the peer serves its first RPC. The
+// states of the instances the peer forgets are dropped from it, see Done.
+// The EPaxos attributes of StartCommand and lease grants are not kept.
+
+// AcceptorState is the acceptor state of one instance, as kept by a
+// Storage.
+type AcceptorState struct {
+	Seq     int
+	NP      int   // highest round promised
+	NA      int   // round of the last vote, -1 if none
+	VA      Value // value of the last vote
+	General bool  // of an instance filled by AppendCommand, VA is a CStruct
+}
+
+// Storage keeps acceptor state across restarts.
+type Storage interface {
+	// Load returns the saved states. A later state of an instance replaces
+	// an earlier one.
+	Load() ([]AcceptorState, error)
+	// Save durably records s before it returns. It is called with the
+	// peer's lock held, so one at a time.
+	Save(s AcceptorState) error
+	// Forget drops the states of the instances below seq, which the peer
+	// forgot, see Done. It is called with the peer's lock held too.
+	Forget(seq int) error
+}
+
+// WithStorage saves the acceptor state of the peer to s, and restores it
+// from s when the peer is made.
+func WithStorage(s Storage) Option {
+	return func(p *Paxos) {
+		p.storage = s
+	}
+}
+
+// restoreAcceptors installs the states saved in p.storage.
+func (p *Paxos) restoreAcceptors() error {
+	if p.storage == nil {
+		return nil
+	}
+	states, err := p.storage.Load()
+	if err != nil {
+		return fmt.Errorf("gopaxos: load acceptor state: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range states {
+		if s.General {
+			p.general[s.Seq] = &generalInstance{rnd: s.NP, vrnd: s.NA, vval: toCStruct(s.VA)}
+		} else {
+			p.fast[s.Seq] = &fastInstance{rnd: s.NP, vrnd: s.NA, vval: s.VA}
+		}
+		if s.Seq > p.maxSeq {
+			p.maxSeq = s.Seq
+		}
+	}
+	return nil
+}
+
+// saveFast saves the acceptor state of round-based instance seq. p.mu must
+// be held.
+func (p *Paxos) saveFast(seq int) error {
+	if p.storage == nil {
+		return nil
+	}
+	inst := p.fast[seq]
+	return p.storage.Save(AcceptorState{Seq: seq, NP: inst.rnd, NA: inst.vrnd, VA: inst.vval})
+}
+
+// saveGeneral saves the acceptor state of generalized instance seq. p.mu
+// must be held.
+func (p *Paxos) saveGeneral(seq int) error {
+	if p.storage == nil {
+		return nil
+	}
+	inst := p.general[seq]
+	return p.storage.Save(AcceptorState{Seq: seq, NP: inst.rnd, NA: inst.vrnd, VA: inst.vval, General: true})
+}
@@ -0,0 +1,96 @@
+package gopaxos
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// memStorage keeps saved states in memory, failing every Save once err is
+// set.
+type memStorage struct {
+	states []AcceptorState
+	err    error
+}
+
+func (s *memStorage) Load() ([]AcceptorState, error) { return s.states, nil }
+
+func (s *memStorage) Save(st AcceptorState) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.states = append(s.states, st)
+	return nil
+}
+
+func (s *memStorage) Forget(seq int) error {
+	var kept []AcceptorState
+	for _, st := range s.states {
+		if st.Seq >= seq {
+			kept = append(kept, st)
+		}
+	}
+	s.states = kept
+	return nil
+}
+
+func TestStorageRestoresAcceptorState(t *testing.T) {
+	fmt.Println("Test: A restarted acceptor keeps its promises and votes ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p"}
+	s := &memStorage{}
+	h := NewHandler(newPaxos(peers, 2, WithStorage(s)))
+	h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 4, Round: 3}, &Response{})
+	h.OnReceiveFastAccept(&Request{FromID: 0, Seq: 4, Round: 3, Value: 42}, &Response{})
+	h.OnReceiveFastPrepare(&Request{FromID: 1, Seq: 4, Round: 7}, &Response{})
+	h.OnReceiveCStructAppend(&Request{FromID: 0, Seq: 5, Value: "x"}, &Response{})
+
+	p := newPaxos(peers, 2, WithStorage(s))
+	if err := p.restoreAcceptors(); err != nil {
+		t.Fatal(err)
+	}
+	h = NewHandler(p)
+	var resp Response
+	h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 4, Round: 6}, &resp)
+	if resp.OK || resp.Round != 3 || resp.Value != 42 {
+		t.Fatalf("prepare(6) after restart = %+v, want: rejected, reporting the vote 42 in round 3", resp)
+	}
+	resp = Response{}
+	h.OnReceiveCStructPrepare(&Request{FromID: 0, Seq: 5, Round: 1}, &resp)
+	if cs, ok := resp.Value.(CStruct); !resp.OK || !ok || len(cs) != 1 || cs[0] != "x" {
+		t.Fatalf("generalized prepare(1) after restart = %+v, want: the vote [x]", resp)
+	}
+	if p.Max() != 5 {
+		t.Fatalf("Max() after restart = %d, want: 5", p.Max())
+	}
+
+	s.err = errors.New("disk full")
+	if err := h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: 4, Round: 9}, &Response{}); err == nil {
+		t.Fatalf("promise answered although it was not saved")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestStorageForgetsInstances(t *testing.T) {
+	fmt.Println("Test: Forgotten instances leave the storage ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p"}
+	s := &memStorage{}
+	p := newPaxos(peers, 2, WithStorage(s))
+	h := NewHandler(p)
+	for _, seq := range []int{1, 3, 5} {
+		h.OnReceiveFastPrepare(&Request{FromID: 0, Seq: seq, Round: 1}, &Response{})
+	}
+
+	p.mu.Lock()
+	for id := range peers {
+		p.recordForget(id, 4)
+	}
+	p.mu.Unlock()
+	if len(s.states) != 1 || s.states[0].Seq != 5 {
+		t.Fatalf("states after forgetting below 4 = %+v, want: the one of instance 5", s.states)
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,72 @@
+package gopaxos
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+)
+
+// With mutual TLS every peer presents a certificate on every connection,
+// in both directions. A client checks that the server's certificate is
+// valid for the host it dialed. A server checks on each request that the
+// client's certificate identifies peers[FromID], so a peer cannot speak for
+// another one.
+//
+// A certificate identifies a peer by a URI SAN whose authority is the
+// host:port of the peer, e.g. paxos://10.0.0.1:7000. A certificate without
+// URI SANs identifies a peer by its host, as a DNS name or IP address, but
+// only if no other peer of the config runs on that host.
+
+// WithTLS secures the net/rpc transport with mutual TLS. config must hold
+// this peer's certificate, and RootCAs and ClientCAs that verify the
+// certificates of the other peers. Every peer's certificate must identify
+// its entry in peers, see above. Every peer of the cluster must enable it.
+// Make panics if it is combined with WithTransport.
+func WithTLS(config *tls.Config) Option {
+	return func(p *Paxos) {
+		p.tls = config
+	}
+}
+
+// Authorize checks that cert, presented by the sender of a request,
+// identifies peer from of the latest known config. Transports call it.
+func (h *Handler) Authorize(cert *x509.Certificate, from int) error {
+	h.pxs.mu.Lock()
+	peers := h.pxs.configs.latest().Peers
+	h.pxs.mu.Unlock()
+	if from < 0 || from >= len(peers) {
+		return fmt.Errorf("unknown peer %d", from)
+	}
+	addr, _, err := splitPeerAddr(peers[from])
+	if err != nil {
+		return err
+	}
+	if len(cert.URIs) > 0 {
+		for _, uri := range cert.URIs {
+			if uri.Host == addr {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer %d: certificate has no URI for %s", from, addr)
+	}
+	host := hostOf(addr)
+	for id, peer := range peers {
+		if other, _, err := splitPeerAddr(peer); err == nil && id != from && hostOf(other) == host {
+			return fmt.Errorf("peer %d: host %s is shared with peer %d, want: a URI SAN for %s", from, host, id, addr)
+		}
+	}
+	if err := cert.VerifyHostname(host); err != nil {
+		return fmt.Errorf("peer %d: %v", from, err)
+	}
+	return nil
+}
+
+// hostOf returns the host of addr, or addr if it has no port.
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
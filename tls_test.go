@@ -0,0 +1,210 @@
+package gopaxos
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for the peers of a test cluster.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gopaxos test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// config returns the TLS config of a peer whose certificate is valid for ip
+// and carries uris as URI SANs.
+func (ca *testCA) config(t *testing.T, ip string, uris ...string) *tls.Config {
+	var sans []*url.URL
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sans = append(sans, u)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: ip},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP(ip)},
+		URIs:         sans,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		RootCAs:      ca.pool,
+		ClientCAs:    ca.pool,
+	}
+}
+
+// loopbackPeer returns a free address on loopback ip, so that every peer of
+// a test cluster has its own identity.
+func loopbackPeer(t *testing.T, ip, tag string) string {
+	l, err := net.Listen("tcp", ip+":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String() + "/" + tag
+}
+
+func TestGoPaxosMutualTLS(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	ca := newTestCA(t)
+	ips := make([]string, npaxos)
+	for i := 0; i < npaxos; i++ {
+		ips[i] = fmt.Sprintf("127.0.0.%d", i+1)
+		pxh[i] = loopbackPeer(t, ips[i], fmt.Sprintf("paxos-%d-mutual-tls", i))
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithTLS(ca.config(t, ips[i])))
+	}
+
+	fmt.Println("Test: Mutual TLS between peers ...")
+
+	pxa[0].StartFast(0, "hello")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(config *tls.Config, from int) error {
+		cp := newClientPool()
+		cp.tls = config
+		defer cp.close()
+		req := &Request{FromID: from, Seq: 1, Round: fastRound, Value: "x", ClusterID: pxa[0].ClusterID()}
+		return cp.call(context.Background(), pxh[0], "Handler.OnReceiveFastAccept", req, &Response{})
+	}
+	// peer 2's certificate may only speak for peer 2.
+	if err := call(ca.config(t, ips[2]), 2); err != nil {
+		t.Fatalf("request of peer 2 = %v, want: nil", err)
+	}
+	if err := call(ca.config(t, ips[2]), 1); err == nil || !strings.Contains(err.Error(), "peer 1") {
+		t.Fatalf("request of peer 2 as peer 1 = %v, want: rejected", err)
+	}
+	// a client without a certificate cannot connect at all.
+	if err := call(&tls.Config{RootCAs: ca.pool}, 2); err == nil {
+		t.Fatalf("request without a client certificate succeeded")
+	}
+	// nor can a client without TLS.
+	if err := call(nil, 2); err == nil {
+		t.Fatalf("request without TLS succeeded")
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestAuthorizeIdentifiesPeers(t *testing.T) {
+	fmt.Println("Test: Certificates identify peers by host:port ...")
+
+	// peers 0 and 1 share a host, peer 2 has its own.
+	ca := newTestCA(t)
+	pxs := newPaxos([]string{"127.0.0.1:7000/p", "127.0.0.1:7001/p", "127.0.0.3:7000/p"}, 2)
+	h := NewHandler(pxs)
+	cert := func(ip string, uris ...string) *x509.Certificate {
+		c, err := x509.ParseCertificate(ca.config(t, ip, uris...).Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		cert *x509.Certificate
+		from int
+		ok   bool
+	}{
+		{cert("127.0.0.3"), 2, true},
+		{cert("127.0.0.3"), 0, false},
+		{cert("127.0.0.1"), 0, false}, // the host alone is ambiguous
+		{cert("127.0.0.1", "paxos://127.0.0.1:7000"), 0, true},
+		{cert("127.0.0.1", "paxos://127.0.0.1:7000"), 1, false},
+		{cert("127.0.0.1", "paxos://127.0.0.1:7001"), 1, true},
+		{cert("127.0.0.3", "paxos://127.0.0.3:7001"), 2, false},
+	}
+	for _, test := range tests {
+		err := h.Authorize(test.cert, test.from)
+		if (err == nil) != test.ok {
+			t.Fatalf("Authorize(%v %v, %d) = %v, want ok: %v", test.cert.IPAddresses, test.cert.URIs, test.from, err, test.ok)
+		}
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestTLSNeedsRPCTransport(t *testing.T) {
+	fmt.Println("Test: WithTLS and WithIdleTimeout reject other transports ...")
+
+	peers := []string{"a:1/p", "b:1/p", "c:1/p"}
+	config := &tls.Config{}
+	pxs := newPaxos(peers, 0, WithTLS(config), WithIdleTimeout(time.Second))
+	if pool := pxs.transport.(*rpcTransport).pool; pool.tls != config || pool.idle != time.Second {
+		t.Fatalf("net/rpc transport has tls %p and idle %v, want: %p and %v", pool.tls, pool.idle, config, time.Second)
+	}
+
+	for _, opt := range []Option{WithTLS(config), WithIdleTimeout(time.Second)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("option combined with WithTransport did not panic")
+				}
+			}()
+			newPaxos(peers, 0, opt, WithTransport(&filterTransport{Transport: newRPCTransport()}))
+		}()
+	}
+
+	fmt.Println("  ... Passed")
+}
@@ -0,0 +1,135 @@
+package gopaxos
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Proposals are traced with OpenTelemetry. Every proposal of this peer is
+// a root span; each round it runs, and each randomized backoff between
+// rounds, is a child of it; each call to a peer is a child of its round.
+// The caller's span context travels in Request.TraceParent and TraceState
+// in the W3C trace context format, and the transports open the acceptor's
+// span under it with Handler.StartSpan, so one proposal yields a single
+// trace across every peer it reached. Without WithTracerProvider spans go
+// to the global provider, which drops them unless the process installs
+// one.
+
+const tracerName = "github.com/yaoshengzhe/gopaxos"
+
+// traceContext is the propagation format of Request.TraceParent and
+// TraceState, independent of the global propagator.
+var traceContext = propagation.TraceContext{}
+
+// WithTracerProvider makes the peer record its spans with tp.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	if tp == nil {
+		panic("invalid tracer provider, want: not nil")
+	}
+	return func(p *Paxos) {
+		p.tracer = tp.Tracer(tracerName)
+	}
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startSpan starts a span of this peer about instance seq.
+func (p *Paxos) startSpan(ctx context.Context, name string, seq int, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		attribute.Int("gopaxos.peer", p.id),
+		attribute.Int("gopaxos.seq", seq),
+	}, attrs...)
+	return p.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startRound starts the span of round of instance seq.
+func (p *Paxos) startRound(ctx context.Context, seq, round int) (context.Context, trace.Span) {
+	return p.startSpan(ctx, "gopaxos.Round", seq, attribute.Int("gopaxos.round", round))
+}
+
+// startCall starts the client span of a call of method to peer, and
+// injects its context into req.
+func (p *Paxos) startCall(ctx context.Context, peer, method string, req *Request) (context.Context, trace.Span) {
+	ctx, span := p.tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("gopaxos.peer", p.id),
+			attribute.Int("gopaxos.seq", req.Seq),
+			attribute.Int("gopaxos.round", req.Round),
+			attribute.String("rpc.system", "gopaxos"),
+			attribute.String("rpc.method", method),
+			attribute.String("server.address", peer),
+		))
+	traceContext.Inject(ctx, requestCarrier{req})
+	return ctx, span
+}
+
+// endSpan ends span, marking it failed if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// remoteContext returns a context carrying the span context of the sender
+// of req, so work done on its behalf joins its trace. Kill cancels it.
+func (p *Paxos) remoteContext(req *Request) context.Context {
+	return traceContext.Extract(p.ctx, requestCarrier{req})
+}
+
+// StartSpan starts the span of this peer serving req for method, as a
+// child of the caller's span, and returns the function that ends it with
+// the method's error. Transports call it around every admitted request.
+func (h *Handler) StartSpan(method string, req *Request) func(error) {
+	p := h.pxs
+	_, span := p.tracer.Start(p.remoteContext(req), method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.Int("gopaxos.peer", p.id),
+			attribute.Int("gopaxos.seq", req.Seq),
+			attribute.Int("gopaxos.round", req.Round),
+			attribute.Int("gopaxos.from", req.FromID),
+			attribute.String("rpc.system", "gopaxos"),
+			attribute.String("rpc.method", method),
+		))
+	return func(err error) {
+		endSpan(span, err)
+	}
+}
+
+// requestCarrier carries a W3C trace context in the fields of a Request.
+type requestCarrier struct {
+	req *Request
+}
+
+func (c requestCarrier) Get(key string) string {
+	switch key {
+	case "traceparent":
+		return c.req.TraceParent
+	case "tracestate":
+		return c.req.TraceState
+	}
+	return ""
+}
+
+func (c requestCarrier) Set(key, value string) {
+	switch key {
+	case "traceparent":
+		c.req.TraceParent = value
+	case "tracestate":
+		c.req.TraceState = value
+	}
+}
+
+func (c requestCarrier) Keys() []string {
+	return []string{"traceparent", "tracestate"}
+}
@@ -0,0 +1,133 @@
+package gopaxos
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// waitSpan waits for exp to hold an ended span named name.
+func waitSpan(exp *tracetest.InMemoryExporter, name string) (tracetest.SpanStub, error) {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		for _, s := range exp.GetSpans() {
+			if s.Name == name {
+				return s, nil
+			}
+		}
+	}
+	return tracetest.SpanStub{}, fmt.Errorf("no %s span", name)
+}
+
+func intAttr(s tracetest.SpanStub, key string) (int, bool) {
+	for _, kv := range s.Attributes {
+		if string(kv.Key) == key {
+			return int(kv.Value.AsInt64()), true
+		}
+	}
+	return 0, false
+}
+
+func TestGoPaxosTracing(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	defer tp.Shutdown(context.Background())
+
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("tracing", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithTracerProvider(tp))
+	}
+
+	fmt.Println("Test: A proposal is one trace across every peer ...")
+
+	// peers 1 and 2 already voted for another value in the fast round, so
+	// peer 0 has to recover in a classic round.
+	for i := 1; i < npaxos; i++ {
+		NewHandler(pxa[i]).OnReceiveFastAccept(&Request{FromID: i, Seq: 0, Round: fastRound, Value: "y"}, &Response{})
+	}
+	pxa[0].StartFast(0, "x")
+	if err := waitN(pxa, 0, npaxos); err != nil {
+		t.Fatal(err)
+	}
+	root, err := waitSpan(exp, "gopaxos.Propose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Parent.IsValid() {
+		t.Fatalf("proposal span has parent %v", root.Parent)
+	}
+
+	traceID := root.SpanContext.TraceID()
+	rounds := make(map[trace.SpanID]int)
+	clients := make(map[trace.SpanID]tracetest.SpanStub)
+	var servers []tracetest.SpanStub
+	for _, s := range exp.GetSpans() {
+		if s.SpanContext.TraceID() != traceID {
+			continue
+		}
+		switch {
+		case s.Name == "gopaxos.Round":
+			if s.Parent.SpanID() != root.SpanContext.SpanID() {
+				t.Fatalf("round span is not a child of the proposal")
+			}
+			round, _ := intAttr(s, "gopaxos.round")
+			rounds[s.SpanContext.SpanID()] = round
+		case s.SpanKind == trace.SpanKindClient:
+			clients[s.SpanContext.SpanID()] = s
+		case s.SpanKind == trace.SpanKindServer:
+			servers = append(servers, s)
+		}
+	}
+	if len(rounds) != 2 {
+		t.Fatalf("%d round spans, want: the fast round and one classic round", len(rounds))
+	}
+
+	prepares := 0
+	for _, c := range clients {
+		if c.Name == "Handler.OnReceiveDecision" {
+			if c.Parent.SpanID() != root.SpanContext.SpanID() {
+				t.Fatalf("decision call is not a child of the proposal")
+			}
+			continue
+		}
+		round, ok := rounds[c.Parent.SpanID()]
+		if !ok {
+			t.Fatalf("%s call is not a child of a round", c.Name)
+		}
+		if c.Name == "Handler.OnReceiveFastPrepare" {
+			if round == fastRound {
+				t.Fatalf("prepare in the fast round")
+			}
+			prepares++
+		}
+	}
+	if prepares != npaxos {
+		t.Fatalf("%d prepare calls, want: %d", prepares, npaxos)
+	}
+
+	peers := make(map[int]bool)
+	for _, s := range servers {
+		c, ok := clients[s.Parent.SpanID()]
+		if !ok || c.Name != s.Name {
+			t.Fatalf("%s served outside of the call that sent it", s.Name)
+		}
+		id, _ := intAttr(s, "gopaxos.peer")
+		peers[id] = true
+	}
+	if len(servers) != len(clients) || len(peers) != npaxos {
+		t.Fatalf("%d server spans on %d peers for %d calls", len(servers), len(peers), len(clients))
+	}
+
+	fmt.Println("  ... Passed")
+}
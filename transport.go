@@ -0,0 +1,112 @@
+package gopaxos
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/rpc"
+	"sync"
+)
+
+// Transport carries the RPCs between peers. Make serves a peer's Handler
+// with it, and every call to another peer goes through it. The default
+// transport is net/rpc over HTTP; WithTransport plugs in another one, such
+// as the gRPC transport in package grpctransport. All peers of a cluster
+// must use the same kind of transport.
+type Transport interface {
+	// Serve answers the RPCs addressed to peer with h until Close.
+	Serve(peer string, h *Handler) error
+
+	// Call invokes method on peer, e.g. "Handler.OnReceiveDecision", and
+	// gives up when ctx is done. resp is only written on success.
+	Call(ctx context.Context, peer, method string, req *Request, resp *Response) error
+
+	// Close stops serving and closes every connection.
+	Close() error
+}
+
+// WithTransport replaces the net/rpc transport.
+func WithTransport(t Transport) Option {
+	return func(p *Paxos) {
+		p.transport = t
+	}
+}
+
+// rpcTransport is the net/rpc transport: each peer serves its Handler on
+// its own HTTP path, and calls go through a clientPool. net/rpc hijacks
+// each served connection from the HTTP server, so the transport tracks
+// them itself to close them on Close.
+type rpcTransport struct {
+	pool   *clientPool
+	listen net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool // hijacked connections being served
+	closed bool
+}
+
+func newRPCTransport() *rpcTransport {
+	return &rpcTransport{pool: newClientPool(), conns: make(map[net.Conn]bool)}
+}
+
+func (t *rpcTransport) Serve(peer string, h *Handler) error {
+	server := rpc.NewServer()
+	server.Register(h)
+	addr, rpcPath, err := splitPeerAddr(peer)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/"+rpcPath, &rpcServer{server: server, h: h, requireCert: t.pool.tls != nil, t: t})
+
+	t.listen, err = net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if t.pool.tls != nil {
+		config := t.pool.tls.Clone()
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		t.listen = tls.NewListener(t.listen, config)
+	}
+	go http.Serve(t.listen, mux)
+	go t.pool.reap()
+	return nil
+}
+
+func (t *rpcTransport) Call(ctx context.Context, peer, method string, req *Request, resp *Response) error {
+	return t.pool.call(ctx, peer, method, req, resp)
+}
+
+func (t *rpcTransport) Close() error {
+	t.pool.close()
+	t.mu.Lock()
+	t.closed = true
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.mu.Unlock()
+	if t.listen != nil {
+		return t.listen.Close()
+	}
+	return nil
+}
+
+// track records a hijacked connection, or reports false if the transport
+// is closed.
+func (t *rpcTransport) track(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.conns[conn] = true
+	return true
+}
+
+func (t *rpcTransport) untrack(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, conn)
+}
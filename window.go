@@ -0,0 +1,86 @@
+package gopaxos
+
+import "context"
+
+// A proposer opens connections to every voter for each instance it runs, so
+// an unbounded number of concurrent proposals can run out of file
+// descriptors. WithWindow bounds the instances this peer proposes in at
+// once: Start, StartFast and AppendCommand block until a slot is free,
+// which pushes back on callers that submit faster than the cluster decides.
+// The Skips of a Mencius owner and the proposals forwarded to it take slots
+// too, but wait for them without blocking anyone.
+
+// WithWindow lets at most n proposals of this peer be in flight at once.
+// Without it the number is unbounded.
+func WithWindow(n int) Option {
+	if n < 1 {
+		panic("invalid window, want: n >= 1")
+	}
+	return func(p *Paxos) {
+		p.window = make(chan struct{}, n)
+	}
+}
+
+// InFlight returns the number of proposals of this peer in flight.
+func (p *Paxos) InFlight() int {
+	return len(p.window)
+}
+
+// propose runs f, a proposal in instance seq, in its own goroutine once a
+// window slot is free, and frees the slot when f returns. f runs under the
+// root span of the proposal, with a context Kill cancels. It must not be
+// called with p.mu held. A forgotten instance is not proposed in.
+func (p *Paxos) propose(seq int, f func(ctx context.Context)) {
+	p.proposeFrom(p.ctx, seq, f)
+}
+
+// proposeFrom is propose with the span of the proposal a child of the one
+// in parent, e.g. the span of the peer that asked for it. parent must be
+// derived from p.ctx.
+func (p *Paxos) proposeFrom(parent context.Context, seq int, f func(ctx context.Context)) {
+	p.mu.Lock()
+	if seq < p.minSeq {
+		p.mu.Unlock()
+		return
+	}
+	p.inflight[seq]++
+	p.mu.Unlock()
+	p.metrics.proposed(seq)
+	run := func() {
+		defer p.proposed(seq)
+		ctx, span := p.startSpan(parent, "gopaxos.Propose", seq)
+		defer span.End()
+		f(ctx)
+	}
+	if p.window == nil {
+		go run()
+		return
+	}
+	select {
+	case p.window <- struct{}{}:
+	case <-p.ctx.Done():
+		p.proposed(seq)
+		return
+	}
+	go func() {
+		defer func() { <-p.window }()
+		run()
+	}()
+}
+
+// proposed notes that a proposal of this peer in seq returned.
+func (p *Paxos) proposed(seq int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inflight[seq]--; p.inflight[seq] <= 0 {
+		delete(p.inflight, seq)
+	}
+}
+
+// proposing reports whether a proposal of this peer in seq is waiting for a
+// window slot or running.
+func (p *Paxos) proposing(seq int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inflight[seq] > 0
+}
@@ -0,0 +1,91 @@
+package gopaxos
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGoPaxosWindow(t *testing.T) {
+	npaxos := 3
+	pxa := make([]*Paxos, npaxos)
+	pxh := make([]string, npaxos)
+	defer cleanup(pxa)
+
+	const window = 3
+	for i := 0; i < npaxos; i++ {
+		pxh[i] = port("window", i)
+	}
+	for i := 0; i < npaxos; i++ {
+		pxa[i] = Make(pxh, i, WithWindow(window))
+	}
+
+	fmt.Println("Test: Proposals are limited to the window ...")
+
+	stop := make(chan bool)
+	peak := make(chan int)
+	go func() {
+		max := 0
+		for {
+			select {
+			case <-stop:
+				peak <- max
+				return
+			default:
+			}
+			for i := 0; i < npaxos; i++ {
+				if n := pxa[i].InFlight(); n > max {
+					max = n
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	// no hand-limiting: StartFast blocks while the window is full.
+	const ninst = 50
+	for seq := 0; seq < ninst; seq++ {
+		for i := 0; i < npaxos; i++ {
+			pxa[i].StartFast(seq, (seq*10)+i)
+		}
+	}
+	for seq := 0; seq < ninst; seq++ {
+		if err := waitN(pxa, seq, npaxos); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	if max := <-peak; max > window {
+		t.Fatalf("%d proposals in flight, want: at most %d", max, window)
+	}
+
+	fmt.Println("  ... Passed")
+}
+
+func TestGoPaxosProposingUntilReturned(t *testing.T) {
+	pxa, pxh, fts := makeFiltered("proposing-until-returned", 3)
+	defer cleanup(pxa)
+
+	fmt.Println("Test: A proposal counts as in flight until it returns ...")
+
+	// peer 0 alone cannot decide, so its proposal keeps running.
+	isolate(pxh, fts, []int{0}, []int{1, 2})
+	pxa[0].Start(0, "x")
+	time.Sleep(300 * time.Millisecond)
+	if !pxa[0].proposing(0) || pxa[0].proposing(1) {
+		t.Fatalf("proposing(0) = %v, proposing(1) = %v, want: true, false", pxa[0].proposing(0), pxa[0].proposing(1))
+	}
+
+	isolate(pxh, fts, nil, nil)
+	if err := waitN(pxa, 0, 3); err != nil {
+		t.Fatal(err)
+	}
+	for iters := 0; pxa[0].proposing(0); iters++ {
+		if iters == 50 {
+			t.Fatalf("proposal in 0 still in flight after the decision")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fmt.Println("  ... Passed")
+}